@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/pflag"
+
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/tomledit"
+)
+
+func init() {
+	registerCommand("cert", "Add, remove or change certificates in the configuration file", runCert)
+}
+
+// certCommands are the subcommands of `certbot-manager cert`.
+var certCommands = map[string]func(args []string) int{
+	"add":    runCertAdd,
+	"remove": runCertRemove,
+	"set":    runCertSet,
+}
+
+// runCert dispatches `cert add|remove|set`. Every edit keeps the comments and formatting of the config file and is
+// validated before the file is written.
+func runCert(args []string) int {
+	if len(args) > 0 {
+		if run, ok := certCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: certbot-manager cert <add|remove|set> [flags]")
+	fmt.Fprintln(os.Stderr, "\n  add <domain>... [--set key=value]...   Add a [[certificate]] block")
+	fmt.Fprintln(os.Stderr, "  remove <domain|number>                 Remove a [[certificate]] block")
+	fmt.Fprintln(os.Stderr, "  set <domain|number> key=value...       Change settings of a [[certificate]] block")
+	if len(args) > 0 && args[0] != "-h" && args[0] != "--help" {
+		fmt.Fprintf(os.Stderr, "\nUnknown cert command '%s'\n", args[0])
+	}
+	return 2
+}
+
+// runCertAdd appends a [[certificate]] block after the existing ones.
+func runCertAdd(args []string) int {
+	fs := newCommandFlags("cert", "cert add <domain>... [--set key=value]... [-c config.toml] [--dry-run]")
+	config.AddFlags(fs)
+	settings := fs.StringArray("set", nil, "Setting of the new certificate, as key=value (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Print the edited configuration instead of saving it")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "At least one domain is required")
+		return 2
+	}
+
+	return editConfig(fs, *dryRun, func(doc *tomledit.Document, certs []configuredCertificate) (string, error) {
+		domains := fs.Args()
+		if err := checkDomains(certs, domains, -1); err != nil {
+			return "", err
+		}
+		keys := [][2]string{{"domains", tomledit.Value(domains)}}
+		for _, setting := range *settings {
+			key, value, err := parseSetting(setting)
+			if err != nil {
+				return "", err
+			}
+			if key == "domains" {
+				return "", fmt.Errorf("domains are given as arguments of cert add, not with --set")
+			}
+			keys = setKey(keys, key, value)
+		}
+		if err := doc.Append("certificate", true, nil, keys); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("added certificate #%d %v", len(certs)+1, domains), nil
+	})
+}
+
+// runCertRemove removes a [[certificate]] block. The lineage is left to the orphan policy.
+func runCertRemove(args []string) int {
+	fs := newCommandFlags("cert", "cert remove <domain|number> [-c config.toml] [--dry-run]")
+	config.AddFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print the edited configuration instead of saving it")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Expected exactly one certificate (a domain or its number)")
+		return 2
+	}
+
+	return editConfig(fs, *dryRun, func(doc *tomledit.Document, certs []configuredCertificate) (string, error) {
+		i, err := findCertificate(certs, fs.Arg(0))
+		if err != nil {
+			return "", err
+		}
+		if err := doc.Remove(doc.Tables("certificate")[i]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("removed certificate #%d %v; globals.orphan_policy applies to its lineage on the next run", i+1, certs[i].Domains), nil
+	})
+}
+
+// runCertSet changes or removes settings of a [[certificate]] block.
+func runCertSet(args []string) int {
+	fs := newCommandFlags("cert", "cert set <domain|number> key=value... [--unset key]... [-c config.toml] [--dry-run]")
+	config.AddFlags(fs)
+	unset := fs.StringArray("unset", nil, "Setting to remove from the certificate (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Print the edited configuration instead of saving it")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 || (fs.NArg() == 1 && len(*unset) == 0) {
+		fmt.Fprintln(os.Stderr, "Expected a certificate (a domain or its number) and at least one key=value or --unset")
+		return 2
+	}
+
+	return editConfig(fs, *dryRun, func(doc *tomledit.Document, certs []configuredCertificate) (string, error) {
+		i, err := findCertificate(certs, fs.Arg(0))
+		if err != nil {
+			return "", err
+		}
+		for _, setting := range fs.Args()[1:] {
+			key, value, err := parseSetting(setting)
+			if err != nil {
+				return "", err
+			}
+			if key == "domains" {
+				_, raw, _ := strings.Cut(setting, "=")
+				if err := checkDomains(certs, splitList(raw), i); err != nil {
+					return "", err
+				}
+			}
+			if err := doc.Tables("certificate")[i].Set(key, value); err != nil {
+				return "", fmt.Errorf("setting '%s': %w", key, err)
+			}
+		}
+		for _, key := range *unset {
+			if key == "domains" {
+				return "", fmt.Errorf("domains can't be unset")
+			}
+			if ok, err := doc.Tables("certificate")[i].Delete(key); err != nil {
+				return "", fmt.Errorf("unsetting '%s': %w", key, err)
+			} else if !ok {
+				return "", fmt.Errorf("certificate #%d %v doesn't set '%s'", i+1, certs[i].Domains, key)
+			}
+		}
+		return fmt.Sprintf("updated certificate #%d %v", i+1, certs[i].Domains), nil
+	})
+}
+
+// configuredCertificate is what the cert commands read from a [[certificate]] block to identify it.
+type configuredCertificate struct {
+	Domains []string `toml:"domains"`
+}
+
+// editConfig applies edit to the config file named by the --config flag, validates the result and writes it back,
+// or prints it with --dry-run.
+func editConfig(fs *pflag.FlagSet, dryRun bool, edit func(*tomledit.Document, []configuredCertificate) (string, error)) int {
+	path, _ := fs.GetString("config")
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".toml" {
+		fmt.Fprintf(os.Stderr, "Only TOML configuration files can be edited, not '%s'\n", path)
+		return 2
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read configuration: %v\n", err)
+		return 2
+	}
+	var parsed struct {
+		Certificates []configuredCertificate `toml:"certificate"`
+	}
+	if err := toml.Unmarshal(data, &parsed); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse '%s': %v\n", path, err)
+		return 2
+	}
+	doc, err := tomledit.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse '%s': %v\n", path, err)
+		return 2
+	}
+
+	message, err := edit(doc, parsed.Certificates)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, tomledit.ErrInvalidEdit) {
+			return 2
+		}
+		return 1
+	}
+	if !validateConfigData(fs, path, doc.Bytes()) {
+		fmt.Fprintf(os.Stderr, "'%s' was not changed.\n", path)
+		return 1
+	}
+
+	if dryRun {
+		_, _ = os.Stdout.Write(doc.Bytes())
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write configuration: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, doc.Bytes(), info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write configuration: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Saved '%s': %s.\n", path, message)
+	return 0
+}
+
+// validateConfigData runs `validate` on data, written to a temporary file next to path so relative paths resolve
+// the same way. Errors are printed with path in place of the temporary file; warnings don't fail the validation.
+func validateConfigData(fs *pflag.FlagSet, path string, data []byte) bool {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.toml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to validate configuration: %v\n", err)
+		return false
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to validate configuration: %v\n", err)
+		return false
+	}
+
+	check := pflag.NewFlagSet("validate", pflag.ContinueOnError)
+	config.AddFlags(check)
+	_ = check.Set("config", tmp.Name())
+	for _, name := range []string{"certbot-path", "log-level", "log-format"} {
+		if flag := fs.Lookup(name); flag != nil && flag.Changed {
+			_ = check.Set(name, flag.Value.String())
+		}
+	}
+
+	report := validate(check)
+	for _, d := range report.Diagnostics {
+		if d.Severity == severityError {
+			fmt.Fprintf(os.Stderr, "[%s] %s: %s\n", d.Severity, d.Scope, strings.ReplaceAll(d.Message, tmp.Name(), path))
+		}
+	}
+	return report.Valid
+}
+
+// parseSetting parses a key=value argument and renders the value as TOML according to the key's type.
+// Lists are comma separated and args are split like a shell command line.
+func parseSetting(setting string) (string, string, error) {
+	name, raw, ok := strings.Cut(setting, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid setting '%s', expected key=value", setting)
+	}
+	name = strings.TrimSpace(name)
+	key, ok := config.CertificateKey(name)
+	if !ok {
+		names := make([]string, 0)
+		for _, key := range config.CertificateKeys() {
+			names = append(names, key.Name)
+		}
+		return "", "", fmt.Errorf("unknown certificate setting '%s' (options: %v)", name, names)
+	}
+
+	switch key.Type {
+	case "bool":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("%s must be true or false, got '%s'", name, raw)
+		}
+		return name, tomledit.Value(value), nil
+	case "int":
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("%s must be a number, got '%s'", name, raw)
+		}
+		return name, tomledit.Value(value), nil
+	case "list":
+		return name, tomledit.Value(splitList(raw)), nil
+	case "args":
+		args, err := config.SplitShellWords(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return name, tomledit.Value(args), nil
+	default:
+		return name, tomledit.Value(raw), nil
+	}
+}
+
+// splitList splits a comma or space separated list.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+// setKey sets key in an ordered list of key/value pairs, replacing an earlier value.
+func setKey(keys [][2]string, key, value string) [][2]string {
+	for i := range keys {
+		if keys[i][0] == key {
+			keys[i][1] = value
+			return keys
+		}
+	}
+	return append(keys, [2]string{key, value})
+}
+
+// findCertificate identifies a certificate by its 1-based number or by one of its domains.
+func findCertificate(certs []configuredCertificate, arg string) (int, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(certs) {
+			return 0, fmt.Errorf("there is no certificate #%d (the configuration has %d)", n, len(certs))
+		}
+		return n - 1, nil
+	}
+	i := findDomain(certs, arg)
+	if i < 0 {
+		return 0, fmt.Errorf("no certificate is configured for '%s'", arg)
+	}
+	return i, nil
+}
+
+// checkDomains returns an error if one of domains is already configured in a certificate other than skip.
+func checkDomains(certs []configuredCertificate, domains []string, skip int) error {
+	for _, domain := range domains {
+		for i, cert := range certs {
+			if i == skip {
+				continue
+			}
+			for _, d := range cert.Domains {
+				if strings.EqualFold(d, domain) {
+					return fmt.Errorf("'%s' is already configured in certificate #%d %v", domain, i+1, cert.Domains)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// findDomain returns the index of the first certificate listing domain, -1 if none does.
+func findDomain(certs []configuredCertificate, domain string) int {
+	for i, cert := range certs {
+		for _, d := range cert.Domains {
+			if strings.EqualFold(d, domain) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// command is a subcommand invoked as `certbot-manager <name> [flags]`.
+// run receives the arguments following the command name and returns the process exit code.
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// commands holds the registered subcommands, keyed by name.
+var commands = map[string]command{}
+
+// registerCommand adds a subcommand. Called from init() functions in the command files.
+func registerCommand(name, summary string, run func(args []string) int) {
+	if _, exists := commands[name]; exists {
+		panic(fmt.Sprintf("command '%s' registered twice", name))
+	}
+	commands[name] = command{summary: summary, run: run}
+}
+
+// aliases maps alternative spellings of subcommands, such as "--version", to their name.
+var aliases = map[string]string{}
+
+// registerAlias makes `certbot-manager <alias>` run the named subcommand.
+func registerAlias(alias, name string) {
+	aliases[alias] = name
+}
+
+// lookupCommand returns the subcommand named by the first argument, if any.
+func lookupCommand(args []string) (command, []string, bool) {
+	if len(args) == 0 {
+		return command{}, nil, false
+	}
+	name := args[0]
+	if target, ok := aliases[name]; ok {
+		name = target
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return command{}, nil, false
+	}
+	return cmd, args[1:], true
+}
+
+// printCommands prints the list of subcommands, sorted by name. It is shown after the --help output.
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("\nCommands (run 'certbot-manager <command> --help' for details):")
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, commands[name].summary)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	cronpkg "certbot-manager/internal/cron"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/metrics"
+	"certbot-manager/internal/state"
+	"certbot-manager/internal/tracing"
+	"certbot-manager/internal/watchdog"
+)
+
+// daemon holds what the long-running manager needs to renew certificates and apply config reloads.
+// mu serializes certbot work: certbot refuses to run concurrently, so a reload waits for a running renewal.
+type daemon struct {
+	mu          sync.Mutex
+	cfg         *config.Config
+	certbotPath string
+	scheduler   *cronpkg.Scheduler
+	health      *healthState
+	expiry      *watchdog.Watchdog
+	expiryCheck *cronpkg.Scheduler // nil while expiry_check_cron is empty
+}
+
+// startJob starts the root span of a job: the initial processing, a renewal, a reload or a single run.
+func startJob(trigger string) (context.Context, trace.Span) {
+	return tracing.Start(context.Background(), "job "+trigger, tracing.AttrTrigger.String(trigger))
+}
+
+// processCertificates reconciles managed lineages, requests every configured certificate and records the
+// resulting lineages. trigger is recorded in the run history. It returns false if any certificate request failed.
+func processCertificates(ctx context.Context, cfg *config.Config, certbotPath string, trigger string) bool {
+	if err := certbot.ReconcileLineages(ctx, cfg, certbotPath, trigger); err != nil {
+		logrus.Errorf("Lineage reconciliation finished with errors: %v", err)
+	}
+
+	before, _ := letsencrypt.Serials(cfg.Globals.ConfigDir)
+	results := certbot.RequestCertificates(ctx, cfg, certbotPath, trigger)
+
+	if err := certbot.RecordLineages(cfg, before, results); err != nil {
+		logrus.Errorf("Failed to record managed lineages: %v", err)
+	}
+	return errors.Join(results...) == nil
+}
+
+// renew is the cron job: it runs 'certbot renew' with the current configuration.
+func (d *daemon) renew() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ctx, span := startJob(history.TriggerCron)
+	log := tracing.WithSpan(ctx, logging.Component("cron"))
+	log.Info("Cron Job: Triggered renewal check...")
+	err := certbot.RenewCertificates(ctx, d.certbotPath, d.cfg, history.TriggerCron)
+	tracing.End(span, err, certbot.ErrorClass(err))
+	if err != nil {
+		log.Warn("Cron Job: Renewal check finished with potential issue.")
+	} else {
+		log.Info("Cron Job: Renewal check finished successfully.")
+	}
+	if err := certbot.RecordRenewal(d.cfg.Globals.StateDir, err); err != nil {
+		log.Errorf("Failed to record renewal result: %v", err)
+	}
+}
+
+// checkExpiry is the expiry watchdog job. It reads the configuration from the health state rather than under d.mu,
+// so it never waits for a running certbot command.
+func (d *daemon) checkExpiry() {
+	d.expiry.Run(d.health.cfg.Load().Globals)
+}
+
+// scheduleExpiryCheck schedules the expiry watchdog on expression, replacing its previous schedule. An empty
+// expression disables it.
+func (d *daemon) scheduleExpiryCheck(expression string) error {
+	var scheduler *cronpkg.Scheduler
+	if expression != "" {
+		var err error
+		if scheduler, err = cronpkg.StartJob("Expiry watchdog", expression, d.checkExpiry); err != nil {
+			return err
+		}
+	}
+	if d.expiryCheck != nil {
+		d.expiryCheck.Stop()
+	}
+	d.expiryCheck = scheduler
+	return nil
+}
+
+// reload reads the configuration again and applies it: lineages are reconciled, new certificates requested
+// and the renewal job rescheduled if its cron expression changed. An invalid configuration is rejected and the
+// current one kept.
+func (d *daemon) reload() {
+	log := logging.Component("config")
+	log.Info("Reloading configuration...")
+	cfg, err := config.Reload()
+	if err != nil {
+		log.Errorf("Config reload failed, keeping the current configuration: %v", err)
+		metrics.ObserveReload(err)
+		auditConfigLoad(audit.ActionConfigReload, history.TriggerReload, nil, d.health.cfg.Load(), err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cfg.Globals.StateDir != d.cfg.Globals.StateDir {
+		if _, err := state.Open(cfg.Globals.StateDir); err != nil {
+			log.Errorf("Config reload failed, keeping the current configuration: %v", err)
+			metrics.ObserveReload(err)
+			auditConfigLoad(audit.ActionConfigReload, history.TriggerReload, nil, d.cfg, err)
+			return
+		}
+	}
+	if cfg.Globals.ListenAddress != d.cfg.Globals.ListenAddress {
+		log.Warnf("globals.listen_address changed to '%s'; it takes effect after a restart.", cfg.Globals.ListenAddress)
+	}
+
+	if cfg.LogLevel != d.cfg.LogLevel || cfg.Globals.LogFormat != d.cfg.Globals.LogFormat ||
+		!reflect.DeepEqual(cfg.Logging, d.cfg.Logging) {
+		// Configure also discards the level changes made by SIGUSR2 or the admin endpoint.
+		log.Info("Applying changed log level and outputs.")
+		if err := logging.Configure(cfg.LogLevel, cfg.Globals.LogFormat, cfg.Logging); err != nil {
+			log.Errorf("Failed to apply log outputs, keeping the current ones: %v", err)
+			cfg.Logging = d.cfg.Logging
+			cfg.LogLevel, cfg.Globals.LogFormat = d.cfg.LogLevel, d.cfg.Globals.LogFormat
+		}
+	}
+
+	if !reflect.DeepEqual(cfg.Tracing, d.cfg.Tracing) {
+		log.Info("Applying changed tracing settings.")
+		if err := tracing.Configure(cfg.Tracing, currentBuildInfo().Version); err != nil {
+			log.Errorf("Failed to apply tracing settings, keeping the current ones: %v", err)
+			cfg.Tracing = d.cfg.Tracing
+		}
+	}
+
+	audit.Configure(cfg.Audit, "daemon")
+	auditConfigLoad(audit.ActionConfigReload, history.TriggerReload, cfg, d.cfg, nil)
+
+	ctx, span := startJob(history.TriggerReload)
+	ok := processCertificates(ctx, cfg, d.certbotPath, history.TriggerReload)
+	if !ok {
+		log.Error("One or more certificate requests failed after reload. Check logs above for details.")
+		tracing.End(span, tracing.ErrFailed, "")
+	} else {
+		tracing.End(span, nil, "")
+	}
+
+	if cfg.Globals.RenewalCron != d.cfg.Globals.RenewalCron {
+		scheduler, err := cronpkg.SetupAndStartScheduler(cfg.Globals.RenewalCron, d.renew)
+		if err != nil {
+			log.Errorf("Failed to reschedule renewal job, keeping '%s': %v", d.cfg.Globals.RenewalCron, err)
+			cfg.Globals.RenewalCron = d.cfg.Globals.RenewalCron
+		} else {
+			// The old scheduler can't be stopped synchronously here: a running renewal holds d.mu.
+			go d.scheduler.Stop()
+			d.scheduler = scheduler
+			d.health.scheduler.Store(scheduler)
+		}
+	}
+
+	if cfg.Globals.ExpiryCheckCron != d.cfg.Globals.ExpiryCheckCron {
+		if err := d.scheduleExpiryCheck(cfg.Globals.ExpiryCheckCron); err != nil {
+			log.Errorf("Failed to reschedule the expiry watchdog, keeping '%s': %v", d.cfg.Globals.ExpiryCheckCron, err)
+			cfg.Globals.ExpiryCheckCron = d.cfg.Globals.ExpiryCheckCron
+		}
+	}
+
+	d.cfg = cfg
+	d.health.cfg.Store(cfg)
+	metrics.ObserveReload(nil)
+	log.Info("Configuration reloaded.")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/state"
+)
+
+func init() {
+	registerCommand("delete", "Delete a managed certificate lineage", runDelete)
+}
+
+// runDelete deletes a managed lineage with `certbot delete` and forgets it in the manager state.
+// The action is recorded in the manager state.
+func runDelete(args []string) int {
+	fs := newCommandFlags("delete", "delete <cert-name> [--yes]")
+	config.AddFlags(fs)
+	yes := fs.BoolP("yes", "y", false, "Don't ask for confirmation")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, certbotPath, st, ok := loadLineageCommand(fs)
+	if !ok {
+		return 2
+	}
+	target, err := resolveTarget(cfg, st, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	name, domains := target.lineage.Name, target.domains()
+	if !*yes && !confirm(fmt.Sprintf("Delete certificate '%s' (%v)? Its files are removed from %s.", name, domains, cfg.Globals.ConfigDir)) {
+		fmt.Fprintln(os.Stderr, "Aborted.")
+		return 1
+	}
+
+	err = target.restoreRenewalConf()
+	if err == nil {
+		err = certbot.DeleteLineage(context.Background(), certbotPath, cfg.Globals, name, history.TriggerManual)
+	}
+	saveErr := state.Update(cfg.Globals.StateDir, func(st *state.State) error {
+		st.RecordAction(state.Action{Action: "delete", Lineage: name, Domains: domains}, err)
+		if err == nil {
+			delete(st.Lineages, name)
+		}
+		return nil
+	})
+	if saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record the deletion: %v\n", saveErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to delete certificate '%s': %v\n", name, err)
+		return 1
+	}
+
+	fmt.Printf("Deleted certificate '%s'.\n", name)
+	if target.cert != nil {
+		warnStillConfigured(name, true)
+	}
+	return 0
+}
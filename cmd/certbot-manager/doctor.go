@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/preflight"
+)
+
+func init() {
+	registerCommand("doctor", "Check the environment for common causes of certbot failures", runDoctor)
+}
+
+// runDoctor runs every registered preflight check and prints pass/warn/fail with a fix hint for each finding.
+// It exits with 1 if any check failed.
+func runDoctor(args []string) int {
+	fs := newCommandFlags("doctor", "doctor [-c config.toml] [--offline] [--output text|json]")
+	config.AddFlags(fs)
+	output := fs.StringP("output", "o", outputText, "Output format (text, json)")
+	offline := fs.Bool("offline", false, "Skip checks that need network access, such as fetching webroot challenges over HTTP")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, outputText, outputJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.Globals.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		return 2
+	}
+
+	env := preflight.Env{Config: cfg, Offline: *offline}
+	env.CertbotPath, env.CertbotErr = certbot.ValidateCertbotPath(cfg.CertbotPath)
+	if env.CertbotErr == nil {
+		env.Plugins, _ = certbot.Plugins(env.CertbotPath)
+	}
+
+	results := preflight.Run(env)
+	if *output == outputJSON {
+		if err := writeJSON(results); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode results: %v\n", err)
+			return 1
+		}
+	} else {
+		printDoctorResults(cfg, results)
+	}
+
+	if preflight.Worst(results) == preflight.StatusFail {
+		return 1
+	}
+	return 0
+}
+
+// printDoctorResults prints the findings grouped by scope.
+func printDoctorResults(cfg *config.Config, results []preflight.Result) {
+	counts := map[preflight.Status]int{}
+	scope := ""
+	for _, result := range results {
+		if result.Scope != scope {
+			scope = result.Scope
+			fmt.Printf("\n%s\n", scopeTitle(cfg, scope))
+		}
+		counts[result.Status]++
+		fmt.Printf("  [%s] %s: %s\n", strings.ToUpper(string(result.Status)), result.Check, result.Message)
+		if result.Hint != "" {
+			fmt.Printf("         fix: %s\n", result.Hint)
+		}
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n",
+		counts[preflight.StatusPass], counts[preflight.StatusWarn], counts[preflight.StatusFail])
+}
+
+// scopeTitle describes a result scope, naming the certificate's domains.
+func scopeTitle(cfg *config.Config, scope string) string {
+	var index int
+	if _, err := fmt.Sscanf(scope, "certificate[%d]", &index); err == nil && index < len(cfg.Certificates) {
+		return fmt.Sprintf("Certificate #%d %v", index+1, cfg.Certificates[index].Domains)
+	}
+	return "Global"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"certbot-manager/internal/config"
+)
+
+func init() {
+	registerCommand("env", "List the environment variables that override the configuration", runEnv)
+}
+
+// runEnv lists every environment variable the configuration reads with the key it overrides, its type and the
+// key's effective value and source. With --dotenv it prints a commented .env template instead.
+func runEnv(args []string) int {
+	fs := newCommandFlags("env", "env [-c config.toml] [--output table|json] [--dotenv]")
+	config.AddFlags(fs)
+	output := fs.StringP("output", "o", "table", "Output format (table, json)")
+	dotenv := fs.Bool("dotenv", false, "Print a commented .env template with the current values")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, "table", outputJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	vars := cfg.EnvVars()
+	switch {
+	case *dotenv:
+		printDotenv(vars)
+	case *output == outputJSON:
+		if err := writeJSON(vars); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode environment variables: %v\n", err)
+			return 1
+		}
+	default:
+		printEnvTable(vars)
+	}
+	return 0
+}
+
+// printEnvTable prints one row per environment variable.
+func printEnvTable(vars []config.EnvVar) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIABLE\tKEY\tTYPE\tVALUE\tSOURCE")
+	for _, ev := range vars {
+		value, source := ev.Value, ev.Source
+		if source == "" {
+			value, source = "unset", "-"
+		}
+		if ev.OverriddenBy != "" {
+			source += ", overridden by " + ev.OverriddenBy
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ev.Name, ev.Key, ev.Type, orDash(value), source)
+	}
+	w.Flush()
+}
+
+// printDotenv prints every variable commented out, set to its current value. Secrets are left empty.
+func printDotenv(vars []config.EnvVar) {
+	fmt.Println("# certbot-manager environment variables, generated by `certbot-manager env --dotenv`.")
+	fmt.Println("# Uncomment a variable to override the configuration file. Variables take precedence over")
+	fmt.Println("# [globals]; command line flags take precedence over CERTBOT_MANAGER_CERTBOTPATH and _LOGLEVEL.")
+	fmt.Println("# The log level is --log-level, else _LOGLEVEL, else _GLOBALS_LOG_LEVEL, else globals.log_level.")
+	for _, ev := range vars {
+		fmt.Println()
+		description := fmt.Sprintf("# %s (%s)", ev.Key, ev.Type)
+		switch {
+		case ev.Secret:
+			description += ", secret"
+		case ev.Source != "":
+			description += fmt.Sprintf(", currently from %s", ev.Source)
+		}
+		if ev.OverriddenBy != "" {
+			description += fmt.Sprintf(", overridden by %s", ev.OverriddenBy)
+		}
+		fmt.Println(description)
+		if ev.Type == "args" {
+			fmt.Println("# Split into arguments like a shell command line.")
+		}
+		value := ev.Value
+		if ev.Secret {
+			value = ""
+		}
+		fmt.Printf("#%s=%s\n", ev.Name, dotenvValue(value))
+	}
+}
+
+// dotenvValue quotes a value when a .env parser would otherwise split or truncate it.
+func dotenvValue(value string) string {
+	if strings.ContainsAny(value, " \t#'\"\\$") {
+		return strconv.Quote(value)
+	}
+	return value
+}
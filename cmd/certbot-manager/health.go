@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	cronpkg "certbot-manager/internal/cron"
+	"certbot-manager/internal/watchdog"
+)
+
+const (
+	// maxCertbotRun is how long a single certbot run may take before /healthz reports the manager as stuck.
+	maxCertbotRun = 30 * time.Minute
+	// schedulerTimeout is how long /healthz waits for the scheduler loop to answer.
+	schedulerTimeout = 2 * time.Second
+)
+
+// Values of the status fields of the health responses.
+const (
+	healthOK       = "ok"
+	healthFail     = "fail"
+	healthCritical = "critical"
+	healthExpired  = "expired"
+	healthMissing  = "missing"
+)
+
+// healthState is what the health endpoints observe of the running manager. The daemon updates it on startup and
+// on every reload; the handlers only read it, so they never wait for a running certbot command.
+type healthState struct {
+	started   time.Time
+	ready     atomic.Bool // The initial certificate processing finished
+	cfg       atomic.Pointer[config.Config]
+	scheduler atomic.Pointer[cronpkg.Scheduler]
+}
+
+func newHealthState(cfg *config.Config) *healthState {
+	h := &healthState{started: time.Now()}
+	h.cfg.Store(cfg)
+	return h
+}
+
+// healthResponse is the JSON body of /healthz and /readyz.
+type healthResponse struct {
+	Status        string              `json:"status"`
+	Checks        []healthCheck       `json:"checks"`
+	UptimeSeconds int64               `json:"uptime_seconds"`
+	Certificates  []certificateHealth `json:"certificates"`
+}
+
+// healthCheck is the result of a single check of an endpoint.
+type healthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// certificateHealth describes a configured certificate's live lineage.
+type certificateHealth struct {
+	Name          string         `json:"name,omitempty"`
+	Domains       []string       `json:"domains"`
+	Status        string         `json:"status"` // ok, critical, expired or missing
+	NotAfter      *time.Time     `json:"not_after,omitempty"`
+	DaysRemaining *int           `json:"days_remaining,omitempty"`
+	LastRun       *lastRunStatus `json:"last_run,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// handleHealthz reports whether the process is responsive: the scheduler loop answers and isn't behind, and no
+// certbot command has been running for longer than maxCertbotRun.
+func (h *healthState) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	var checks []healthCheck
+
+	if scheduler := h.scheduler.Load(); scheduler != nil {
+		check := healthCheck{Name: "scheduler", Status: healthOK}
+		next, err := scheduler.Next(schedulerTimeout)
+		switch {
+		case err != nil:
+			check.Status, check.Message = healthFail, err.Error()
+		case !next.IsZero() && now.Sub(next) > time.Minute:
+			check.Status, check.Message = healthFail, fmt.Sprintf("renewal job was due at %s and hasn't started", next.Format(time.RFC3339))
+		case !next.IsZero():
+			check.Message = fmt.Sprintf("next renewal at %s", next.Format(time.RFC3339))
+		}
+		checks = append(checks, check)
+	}
+
+	check := healthCheck{Name: "certbot", Status: healthOK, Message: "idle"}
+	if command, since, ok := certbot.Running(); ok {
+		check.Message = fmt.Sprintf("'certbot %s' running for %s", command, now.Sub(since).Round(time.Second))
+		if now.Sub(since) > maxCertbotRun {
+			check.Status = healthFail
+			check.Message += fmt.Sprintf(", longer than %s", maxCertbotRun)
+		}
+	}
+	checks = append(checks, check)
+
+	h.respond(w, checks, now, false)
+}
+
+// handleReadyz reports whether the manager is serving valid certificates: the initial processing finished and
+// every configured certificate has a lineage that isn't expired or within expiry_critical_days of expiry.
+func (h *healthState) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	check := healthCheck{Name: "initial_processing", Status: healthOK}
+	if !h.ready.Load() {
+		check.Status, check.Message = healthFail, "initial certificate processing hasn't finished"
+	}
+	h.respond(w, []healthCheck{check}, time.Now(), true)
+}
+
+// respond writes the response with the certificates' detail. With checkCertificates, a "certificates" check is
+// added that fails if any certificate is expired, missing or within the critical window.
+func (h *healthState) respond(w http.ResponseWriter, checks []healthCheck, now time.Time, checkCertificates bool) {
+	certs, err := h.certificates(now)
+	if checkCertificates {
+		check := healthCheck{Name: "certificates", Status: healthOK}
+		var failing []string
+		for _, cert := range certs {
+			if cert.Status != healthOK {
+				failing = append(failing, fmt.Sprintf("%v %s", cert.Domains, cert.Status))
+			}
+		}
+		switch {
+		case err != nil:
+			check.Status, check.Message = healthFail, err.Error()
+		case len(failing) > 0:
+			check.Status, check.Message = healthFail, fmt.Sprintf("%d certificate(s) need attention: %v", len(failing), failing)
+		}
+		checks = append(checks, check)
+	}
+
+	response := healthResponse{Status: healthOK, Checks: checks, UptimeSeconds: int64(now.Sub(h.started).Seconds()), Certificates: certs}
+	if response.Certificates == nil {
+		response.Certificates = []certificateHealth{}
+	}
+	code := http.StatusOK
+	for _, check := range checks {
+		if check.Status != healthOK {
+			response.Status, code = healthFail, http.StatusServiceUnavailable
+		}
+	}
+	writeJSONResponse(w, code, response)
+}
+
+// certificates inspects the lineage of every configured certificate, like `status` does. Certificates are critical
+// within expiry_critical_days of expiry, like the expiry watchdog reports them.
+func (h *healthState) certificates(now time.Time) ([]certificateHealth, error) {
+	cfg := h.cfg.Load()
+	thresholds := watchdog.ThresholdsOf(cfg.Globals)
+	statuses, err := collectStatus(cfg, now)
+	if err != nil {
+		return nil, err
+	}
+	var certs []certificateHealth
+	for _, s := range statuses {
+		if s.Certificate == nil {
+			continue // Orphaned lineages don't affect readiness
+		}
+		cert := certificateHealth{
+			Name: s.Name, Domains: s.Domains, Status: healthOK,
+			NotAfter: s.NotAfter, DaysRemaining: s.DaysRemaining, LastRun: s.LastRun, Error: s.Error,
+		}
+		if s.Name == "" || s.NotAfter == nil {
+			cert.Status = healthMissing
+		} else {
+			switch severity, _ := watchdog.Classify(*s.NotAfter, now, thresholds); severity {
+			case watchdog.SeverityExpired:
+				cert.Status = healthExpired
+			case watchdog.SeverityCritical:
+				cert.Status = healthCritical
+			}
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"certbot-manager/internal/config"
+)
+
+func init() {
+	registerCommand("healthcheck", "Query the health endpoint of a running manager", runHealthcheck)
+}
+
+// listenAddressEnv is the environment variable overriding globals.listen_address.
+const listenAddressEnv = config.EnvPrefix + "_GLOBALS_LISTEN_ADDRESS"
+
+// runHealthcheck requests /healthz (or /readyz) from a running manager and exits 0 only if it answered 200, so it
+// can serve as a container HEALTHCHECK in images without curl or wget. It doesn't load the configuration file; the
+// address comes from --url or the listen address environment variable.
+func runHealthcheck(args []string) int {
+	fs := newCommandFlags("healthcheck", "healthcheck [--url http://127.0.0.1:9300] [--ready] [--quiet]")
+	url := fs.String("url", "", "Base URL of the manager's HTTP listener (default: derived from "+listenAddressEnv+")")
+	ready := fs.Bool("ready", false, "Query /readyz instead of /healthz")
+	quiet := fs.BoolP("quiet", "q", false, "Don't print the response body")
+	timeout := fs.Duration("timeout", 5*time.Second, "Time to wait for the response")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+
+	base := *url
+	if base == "" {
+		addr := os.Getenv(listenAddressEnv)
+		if addr == "" {
+			fmt.Fprintf(os.Stderr, "No address to query; pass --url or set %s\n", listenAddressEnv)
+			return 2
+		}
+		var err error
+		if base, err = localURL(addr); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid %s '%s': %v\n", listenAddressEnv, addr, err)
+			return 2
+		}
+	}
+	path := "/healthz"
+	if *ready {
+		path = "/readyz"
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(base + path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Health check failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if !*quiet {
+		_, _ = io.Copy(os.Stdout, resp.Body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Health check failed: %s returned %s\n", path, resp.Status)
+		return 1
+	}
+	return 0
+}
+
+// localURL turns a listen address like ":9300" or "0.0.0.0:9300" into a URL reaching it from the same host.
+func localURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	switch host {
+	case "", "0.0.0.0", "::":
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
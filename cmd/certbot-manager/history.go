@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+)
+
+func init() {
+	registerCommand("history", "Show the recorded certbot runs", runHistory)
+}
+
+// runHistory lists the certbot runs recorded in the state directory, newest first.
+func runHistory(args []string) int {
+	fs := newCommandFlags("history", "history [-c config.toml] [--cert name] [--since 7d] [--failed] [--output table|json|yaml]")
+	config.AddFlags(fs)
+	cert := fs.String("cert", "", "Only show runs of this certificate (lineage name or domain)")
+	since := fs.String("since", "", "Only show runs started within this period (e.g. 7d, 12h) or since this date (2006-01-02)")
+	failed := fs.Bool("failed", false, "Only show failed runs")
+	limit := fs.IntP("limit", "n", 0, "Show at most this many runs (0 shows all)")
+	verbose := fs.BoolP("verbose", "v", false, "Print the error and stderr tail of failed runs (table output)")
+	output := fs.StringP("output", "o", "table", "Output format (table, json, yaml)")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, "table", outputJSON, outputYAML); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = parseSince(*since, time.Now()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	records, err := history.Read(cfg.Globals.StateDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	matching := []history.Record{}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		switch {
+		case *cert != "" && !strings.EqualFold(r.Cert, *cert) && !slices.Contains(r.Domains, strings.ToLower(*cert)):
+		case !from.IsZero() && r.Start.Before(from):
+		case *failed && r.Succeeded():
+		default:
+			matching = append(matching, r)
+		}
+		if *limit > 0 && len(matching) == *limit {
+			break
+		}
+	}
+
+	switch *output {
+	case outputJSON:
+		err = writeJSON(matching)
+	case outputYAML:
+		err = writeYAML(matching)
+	default:
+		printHistoryTable(matching, *verbose)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode history: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseSince parses --since: a number of days (7d), a Go duration (12h, 90m) or a date (2006-01-02).
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since '%s': use a period such as 7d or 12h, or a date such as 2006-01-02", value)
+}
+
+// printHistoryTable prints one row per run. With verbose, the error and stderr tail of failed runs follow their row.
+func printHistoryTable(records []history.Record, verbose bool) {
+	if len(records) == 0 {
+		fmt.Println("No recorded runs found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tCERT\tCMD\tTRIGGER\tDURATION\tEXIT\tRESULT\tSERIAL")
+	for _, r := range records {
+		name := r.Cert
+		if name == "" {
+			name = "-"
+		}
+		serial := "unchanged"
+		switch {
+		case r.SerialAfter == "" && r.SerialBefore != "":
+			serial = "removed"
+		case r.SerialAfter == "":
+			serial = "-"
+		case r.Issued():
+			serial = "new " + shortSerial(r.SerialAfter)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", r.Start.Local().Format("2006-01-02 15:04:05"), name, r.Command,
+			r.Trigger, r.End.Sub(r.Start).Round(100*time.Millisecond), r.ExitCode, r.ErrorClass, serial)
+		if verbose && !r.Succeeded() {
+			w.Flush()
+			fmt.Printf("    error: %s\n", r.Error)
+			if r.StderrTail != "" {
+				for _, line := range strings.Split(r.StderrTail, "\n") {
+					fmt.Printf("    | %s\n", line)
+				}
+			}
+		}
+	}
+	w.Flush()
+}
+
+// shortSerial abbreviates a hex serial for the table.
+func shortSerial(serial string) string {
+	if len(serial) > 12 {
+		return serial[:12] + "…"
+	}
+	return serial
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/importer"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/state"
+)
+
+func init() {
+	registerCommand("import", "Generate a configuration from an existing certbot configuration directory", runImport)
+}
+
+// runImport prints a config.toml equivalent to the lineages found in a certbot configuration directory. With
+// --adopt, it also records the lineages in the manager state, so orphan_policy applies to them.
+func runImport(args []string) int {
+	fs := newCommandFlags("import", "import [--from /etc/letsencrypt] [--output config.toml] [--force] [--adopt [--state-dir dir]]")
+	from := fs.String("from", letsencrypt.DefaultConfigDir, "Certbot configuration directory to import")
+	output := fs.StringP("output", "o", "", "Write the configuration to this file instead of stdout")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	adopt := fs.Bool("adopt", false, "Record the imported lineages as managed by certbot-manager")
+	stateDir := fs.String("state-dir", "", "Manager state directory for --adopt (default <from>/certbot-manager)")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if *adopt && *output == "" {
+		fmt.Fprintln(os.Stderr, "--adopt requires --output, so the adopted lineages are configured")
+		return 2
+	}
+
+	result, err := importer.Import(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import '%s': %v\n", *from, err)
+		return 1
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteTOML(&buf); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate configuration: %v\n", err)
+		return 1
+	}
+
+	if *output == "" {
+		_, _ = os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if _, err := os.Stat(*output); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "'%s' already exists; use --force to overwrite it\n", *output)
+		return 1
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write configuration to '%s': %v\n", *output, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d certificate(s) into '%s'.\n", len(result.Certificates), *output)
+	if *adopt {
+		return adoptLineages(*from, *stateDir, result)
+	}
+	return 0
+}
+
+// adoptLineages records the lineages of result in the manager state in stateDir, <from>/certbot-manager if empty.
+func adoptLineages(from, stateDir string, result *importer.Result) int {
+	if stateDir == "" {
+		stateDir = filepath.Join(from, "certbot-manager")
+	}
+	lineages, err := letsencrypt.Lineages(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read lineages in '%s': %v\n", from, err)
+		return 1
+	}
+	imported := make(map[string]bool, len(result.Certificates))
+	for _, cert := range result.Certificates {
+		imported[cert.Lineage] = true
+	}
+	var adopt []letsencrypt.Lineage
+	for _, lineage := range lineages {
+		if imported[lineage.Name] {
+			adopt = append(adopt, lineage)
+		}
+	}
+	adopted, err := certbot.Adopt(stateDir, adopt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record adopted lineages: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Adopted %d lineage(s) in '%s'.\n", len(adopted), state.Path(stateDir))
+	return 0
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"certbot-manager/internal/certbot/authenticators"
+	"certbot-manager/internal/config"
+	cronpkg "certbot-manager/internal/cron"
+	"certbot-manager/internal/importer"
+	"certbot-manager/internal/tomledit"
+)
+
+func init() {
+	registerCommand("init", "Create a configuration file by answering a few questions", runInit)
+}
+
+// errAborted is returned by the prompts when the input ends before the wizard is done.
+var errAborted = errors.New("aborted")
+
+// initAnswers are the values collected by the init wizard.
+type initAnswers struct {
+	Email         string
+	Staging       bool
+	RenewalCron   string
+	Domains       []string
+	Authenticator string
+	Settings      [][2]string // Authenticator settings, as rendered TOML values
+}
+
+// runInit prompts for the global settings and a first certificate, then writes a commented config file.
+// The file is validated before it is written.
+func runInit(args []string) int {
+	fs := newCommandFlags("init", "init [-c config.toml] [--force]")
+	config.AddFlags(fs)
+	force := fs.Bool("force", false, "Overwrite the configuration file if it exists")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+
+	path, _ := fs.GetString("config")
+	if !strings.HasSuffix(strings.ToLower(path), ".toml") {
+		fmt.Fprintf(os.Stderr, "init writes TOML; use a .toml path instead of '%s'\n", path)
+		return 2
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "'%s' already exists; use --force to overwrite it, or 'certbot-manager cert add' to add certificates\n", path)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "This creates '%s'. Press Enter to accept the [default].\n\n", path)
+	answers, err := askInitAnswers(bufio.NewReader(os.Stdin))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%v\n", err)
+		return 1
+	}
+
+	data := initConfig(answers)
+	if !validateConfigData(fs, path, data) {
+		fmt.Fprintf(os.Stderr, "'%s' was not written.\n", path)
+		return 1
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write configuration to '%s': %v\n", path, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\nWrote '%s'. Check it with 'certbot-manager doctor -c %s'.\n", path, path)
+	return 0
+}
+
+// askInitAnswers runs the wizard's questions. Every answer is checked before moving on.
+func askInitAnswers(in *bufio.Reader) (*initAnswers, error) {
+	a := &initAnswers{}
+	var err error
+
+	if a.Email, err = ask(in, "Email for expiry notices and account recovery", "", func(s string) error {
+		if !strings.Contains(s, "@") {
+			return fmt.Errorf("'%s' is not an email address", s)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	staging, err := ask(in, "Use the Let's Encrypt staging environment while testing? (yes/no)", "yes", checkBool)
+	if err != nil {
+		return nil, err
+	}
+	a.Staging, _ = parseYesNo(staging)
+
+	if a.RenewalCron, err = ask(in, "Renewal schedule (cron with seconds: sec min hour day month weekday)", importer.DefaultRenewalCron, func(s string) error {
+		_, err := cronpkg.NextRuns(s, time.Now(), 1)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	domains, err := ask(in, "Domains of the first certificate (comma separated)", "", func(s string) error {
+		if len(splitList(s)) == 0 {
+			return fmt.Errorf("at least one domain is required")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Domains = splitList(domains)
+
+	names := authenticators.Names()
+	if a.Authenticator, err = ask(in, fmt.Sprintf("Authenticator (%s)", strings.Join(names, ", ")), "webroot", func(s string) error {
+		if !slices.Contains(names, s) {
+			return fmt.Errorf("unknown authenticator '%s'", s)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, name := range authenticators.Settings(a.Authenticator) {
+		key, _ := config.CertificateKey(name)
+		value, err := askSetting(in, key)
+		if err != nil {
+			return nil, err
+		}
+		a.Settings = append(a.Settings, [2]string{name, value})
+	}
+	return a, nil
+}
+
+// askSetting asks for an authenticator setting. Secrets default to an environment variable reference, so they
+// don't end up in the file.
+func askSetting(in *bufio.Reader, key config.Key) (string, error) {
+	def := ""
+	question := key.Name
+	switch {
+	case key.Secret:
+		def = "${" + strings.ToUpper(key.Name) + "}"
+		question += " (secret; the default reads it from the environment)"
+	case key.Name == "dns_propagation_seconds":
+		def = "60"
+	}
+
+	var value string
+	_, err := ask(in, question, def, func(s string) error {
+		if name, ok := strings.CutPrefix(s, "${"); ok && key.Secret {
+			// The configuration is validated before it is written, so the variable must be set already.
+			if _, set := os.LookupEnv(strings.TrimSuffix(name, "}")); !set {
+				return fmt.Errorf("%s is not set; export it before running init, or enter the value", s)
+			}
+		}
+		_, rendered, err := parseSetting(key.Name + "=" + s)
+		value = rendered
+		return err
+	})
+	return value, err
+}
+
+// ask prints a question with its default and reads answers until check accepts one.
+func ask(in *bufio.Reader, question, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(os.Stderr, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ", question)
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", errAborted
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" {
+			fmt.Fprintln(os.Stderr, "  an answer is required")
+			continue
+		}
+		if err := check(answer); err != nil {
+			fmt.Fprintf(os.Stderr, "  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// checkBool accepts yes/no answers.
+func checkBool(s string) error {
+	if _, ok := parseYesNo(s); !ok {
+		return fmt.Errorf("answer yes or no")
+	}
+	return nil
+}
+
+// parseYesNo parses yes/no, y/n and true/false.
+func parseYesNo(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "y", "yes":
+		return true, true
+	case "n", "no":
+		return false, true
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
+
+// initConfig renders the answers as a commented config.toml.
+func initConfig(a *initAnswers) []byte {
+	var b strings.Builder
+	b.WriteString("# certbot-manager configuration, generated by `certbot-manager init`.\n")
+	b.WriteString("# See docs/configurations.md for every setting, and run `certbot-manager validate` after editing.\n\n")
+
+	b.WriteString("[globals]\n")
+	b.WriteString("# Let's Encrypt sends expiry notices to this address\n")
+	fmt.Fprintf(&b, "email = %s\n", tomledit.Value(a.Email))
+	b.WriteString("# Staging certificates aren't trusted by browsers; set to false once issuance works\n")
+	fmt.Fprintf(&b, "staging = %t\n", a.Staging)
+	b.WriteString("# When to run `certbot renew` (seconds minutes hours day-of-month month day-of-week)\n")
+	fmt.Fprintf(&b, "renewal_cron = %s\n", tomledit.Value(a.RenewalCron))
+
+	b.WriteString("\n# Add more certificates with `certbot-manager cert add <domain>...`.\n")
+	b.WriteString("[[certificate]]\n")
+	fmt.Fprintf(&b, "domains = %s\n", tomledit.Value(a.Domains))
+	fmt.Fprintf(&b, "authenticator = %s\n", tomledit.Value(a.Authenticator))
+	for _, kv := range a.Settings {
+		fmt.Fprintf(&b, "%s = %s\n", kv[0], kv[1])
+	}
+	return []byte(b.String())
+}
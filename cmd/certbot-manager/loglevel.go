@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/logging"
+)
+
+// toggleDebug switches the manager between debug logging and its configured level on SIGUSR2.
+func toggleDebug() {
+	level := logging.ToggleDebug()
+	logging.Component("logging").Warnf("Log level set to %s (SIGUSR2).", level)
+	audit.Record(audit.Event{Action: audit.ActionLogLevel, Details: map[string]any{"level": level.String(), "via": "SIGUSR2"}}, nil)
+}
+
+// logLevelRequest is the JSON body of a PUT or POST to /admin/log-level. Reset returns to the configured level
+// and overrides first; Level and Overrides, when given, then replace the ones in effect.
+type logLevelRequest struct {
+	Level     string             `json:"level"`
+	Overrides []logging.Override `json:"overrides"`
+	Reset     bool               `json:"reset"`
+}
+
+// handleAdminLogLevel reports the log levels in effect on GET and changes them on PUT or POST. It requires
+// globals.admin_token as a bearer token, and is not found when no token is configured.
+func (h *healthState) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	token := h.cfg.Load().Globals.AdminToken
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="certbot-manager"`)
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, logging.CurrentLevels())
+		return
+	case http.MethodPut, http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var req logLevelRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if err := applyLogLevelRequest(req); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	state := logging.CurrentLevels()
+	logging.Component("logging").Warnf("Log level set to %s with %d override(s) (admin endpoint, %s).",
+		state.Level, len(state.Overrides), r.RemoteAddr)
+	audit.Record(audit.Event{Action: audit.ActionLogLevel, Details: map[string]any{
+		"level":     state.Level,
+		"overrides": state.Overrides,
+		"reset":     req.Reset,
+		"via":       "admin endpoint",
+		"remote":    r.RemoteAddr,
+	}}, nil)
+	writeJSONResponse(w, http.StatusOK, state)
+}
+
+// applyLogLevelRequest validates req, then applies it: nothing is changed if any part is invalid.
+func applyLogLevelRequest(req logLevelRequest) error {
+	if req.Level == "" && req.Overrides == nil && !req.Reset {
+		return fmt.Errorf("nothing to change: set level, overrides or reset")
+	}
+	if req.Level != "" {
+		if _, err := logrus.ParseLevel(req.Level); err != nil {
+			return err
+		}
+	}
+	for i, override := range req.Overrides {
+		if err := override.Validate(); err != nil {
+			return fmt.Errorf("override #%d: %w", i+1, err)
+		}
+	}
+
+	if req.Reset {
+		logging.ResetLevels()
+	}
+	if req.Level != "" {
+		_ = logging.SetLevel(req.Level)
+	}
+	if req.Overrides != nil {
+		_ = logging.SetOverrides(req.Overrides)
+	}
+	return nil
+}
@@ -6,44 +6,94 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 
+	"certbot-manager/internal/audit"
 	"certbot-manager/internal/certbot"
 	"certbot-manager/internal/config"
 	cronpkg "certbot-manager/internal/cron"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/state"
+	"certbot-manager/internal/tracing"
+	"certbot-manager/internal/watchdog"
 )
 
 func main() {
+	// --- Dispatch Subcommands ---
+	if cmd, args, ok := lookupCommand(os.Args[1:]); ok {
+		os.Exit(cmd.run(args))
+	}
+
 	// --- Load Configuration ---
+	config.HelpFooter = printCommands
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	runManager(cfg)
+}
+
+// runManager runs the long-lived manager: it requests the configured certificates, then renews them on the
+// renewal_cron schedule until it receives SIGINT or SIGTERM.
+func runManager(cfg *config.Config) {
 	// --- Setup Logging ---
-	if err := logging.Setup(cfg.LogLevel); err != nil {
+	if err := logging.Configure(cfg.LogLevel, cfg.Globals.LogFormat, cfg.Logging); err != nil {
 		log.Fatalf("Failed to setup logging: %v", err)
 	}
 
-	logrus.Info("Starting Certbot Manager...")
+	logrus.Infof("Starting Certbot Manager %s...", currentBuildInfo())
+	audit.Configure(cfg.Audit, "daemon")
+	auditConfigLoad(audit.ActionConfigLoad, history.TriggerStartup, cfg, nil, nil)
+
+	// --- Setup Tracing ---
+	if err := tracing.Configure(cfg.Tracing, currentBuildInfo().Version); err != nil {
+		logrus.Fatalf("Failed to setup tracing: %v", err)
+	}
+
+	if cfg.Globals.RenewalCron == "" {
+		logrus.Fatal("globals.renewal_cron is required unless running with 'run --once'")
+	}
 
 	// --- Validate Certbot Path ---
 	validatedCertbotPath, err := certbot.ValidateCertbotPath(cfg.CertbotPath)
 	if err != nil {
 		logrus.Fatalf("Certbot path validation failed: %v", err)
 	}
+	logrus.Infof("Using certbot %s", detectCertbot(validatedCertbotPath))
+
+	// --- Check Manager State ---
+	if _, err := state.Open(cfg.Globals.StateDir); err != nil {
+		logrus.Fatalf("Failed to open manager state: %v", err)
+	}
+
+	// --- Start HTTP Listener ---
+	health := newHealthState(cfg)
+	server, err := startHTTPServer(cfg.Globals.ListenAddress, health)
+	if err != nil {
+		logrus.Fatalf("Failed to start HTTP listener: %v", err)
+	}
+
+	// --- Initial Certificate Request ---
+	ctx, span := startJob(history.TriggerStartup)
+	initialRunsOk := processCertificates(ctx, cfg, validatedCertbotPath, history.TriggerStartup)
+	if initialRunsOk {
+		tracing.End(span, nil, "")
+	} else {
+		tracing.End(span, tracing.ErrFailed, "")
+	}
 
 	// --- Check if certificates need processing ---
 	if len(cfg.Certificates) == 0 {
 		logrus.Info("No [[certificate]] blocks found in configuration. Nothing to schedule.")
+		tracing.Shutdown()
 		os.Exit(0)
 	}
 
-	// --- Initial Certificate Request ---
-	initialRunsOk := certbot.RequestCertificates(cfg, validatedCertbotPath)
-
 	// --- !!! Check for Initial Failures !!! ---
 	if !initialRunsOk {
+		tracing.Shutdown()
 		logrus.Fatal("FATAL: One or more initial certificate requests failed. " +
 			"Check logs above for details. Application will not start the renewal scheduler.",
 		)
@@ -51,32 +101,48 @@ func main() {
 
 	logrus.Info("Initial certificates processing completed successfully.")
 
-	// --- Define the Renewal Job Function ---
-	renewalJob := func() {
-		logrus.Info("Cron Job: Triggered renewal check...")
-		err := certbot.RenewCertificates(validatedCertbotPath)
-		if err != nil {
-			logrus.Warn("Cron Job: Renewal check finished with potential issue.")
-		} else {
-			logrus.Info("Cron Job: Renewal check finished successfully.")
-		}
-	}
+	d := &daemon{cfg: cfg, certbotPath: validatedCertbotPath, health: health, expiry: watchdog.New()}
 
 	// --- Setup and Start Cron Scheduler ---
-	scheduler, err := cronpkg.SetupAndStartScheduler(cfg.Globals.RenewalCron, renewalJob)
+	scheduler, err := cronpkg.SetupAndStartScheduler(cfg.Globals.RenewalCron, d.renew)
 	if err != nil {
 		logrus.Fatalf("Failed to setup and start cron scheduler: %v", err)
 	}
+	d.scheduler = scheduler
+	health.scheduler.Store(scheduler)
+	health.ready.Store(true)
+
+	// --- Setup Expiry Watchdog ---
+	if err := d.scheduleExpiryCheck(cfg.Globals.ExpiryCheckCron); err != nil {
+		logrus.Fatalf("Failed to schedule the expiry watchdog: %v", err)
+	}
+	if d.expiryCheck != nil {
+		d.checkExpiry()
+	}
 
 	// --- Wait for Shutdown Signal ---
-	logrus.Info("Certbot Manager running. Renewal checks scheduled via cron. Waiting for signals...")
+	logrus.Info("Certbot Manager running. Renewal checks scheduled via cron. " +
+		"Waiting for signals (SIGHUP reloads the configuration, SIGUSR2 toggles debug logging)...")
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}, debugSignals...)...)
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			d.reload()
+		} else if slices.Contains(debugSignals, sig) {
+			toggleDebug()
+		} else {
+			break
+		}
+	}
 
 	// --- Initiate Graceful Shutdown ---
 	logrus.Info("Shutdown signal received...")
-	scheduler.Stop()
+	d.scheduler.Stop()
+	if d.expiryCheck != nil {
+		d.expiryCheck.Stop()
+	}
+	stopHTTPServer(server)
+	tracing.Shutdown()
 
 	logrus.Info("Certbot Manager application stopped.")
 }
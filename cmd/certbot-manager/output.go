@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Values accepted by the --output flag of the reporting subcommands.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// newCommandFlags returns the flag set of a subcommand, printing its summary and flags on --help.
+func newCommandFlags(name, usage string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: certbot-manager %s\n\n%s\n\nFlags:\n", usage, commands[name].summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseCommandFlags parses a subcommand's flags. It returns the exit code to use and false when the command
+// must stop, either because --help was requested or the flags are invalid.
+func parseCommandFlags(fs *pflag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0, false
+		}
+		return 2, false
+	}
+	return 0, true
+}
+
+// checkOutputFormat validates the value of an --output flag against the formats a command supports.
+func checkOutputFormat(format string, supported ...string) error {
+	for _, s := range supported {
+		if format == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s' (options: %v)", format, supported)
+}
+
+// writeJSON prints v to stdout as indented JSON.
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeYAML prints v to stdout as YAML.
+func writeYAML(v any) error {
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/logging"
+)
+
+func init() {
+	registerCommand("plan", "Show the certbot command each certificate would run and where every value comes from", runPlan)
+}
+
+// certificatePlan is the effective certbot invocation of a certificate, with secrets masked.
+type certificatePlan struct {
+	Index   int           `json:"index"`
+	Domains []string      `json:"domains"`
+	Command []string      `json:"command,omitempty"`
+	Steps   []planStep    `json:"steps,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	DryRun  *dryRunResult `json:"dry_run,omitempty"`
+}
+
+// planStep holds the arguments one build step contributed and the settings it read.
+type planStep struct {
+	Step     string        `json:"step"`
+	Args     []string      `json:"args"`
+	Settings []planSetting `json:"settings"`
+}
+
+// planSetting is the effective value of a setting and the layer it came from
+// (cert, profile:<name>, global, env or default). Source is empty if no layer sets it.
+type planSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// dryRunResult is the outcome of `certbot --dry-run` for a certificate.
+type dryRunResult struct {
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runPlan prints the certbot command of every certificate, broken down by the step that produced each argument.
+// With --dry-run it also runs each command against the staging server without saving anything, and exits with 1
+// if a command could not be built or a dry run failed.
+func runPlan(args []string) int {
+	fs := newCommandFlags("plan", "plan [-c config.toml] [--dry-run] [--output text|json]")
+	config.AddFlags(fs)
+	output := fs.StringP("output", "o", outputText, "Output format (text, json)")
+	dryRun := fs.Bool("dry-run", false, "Run each command with certbot --dry-run against the staging server")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, outputText, outputJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.Globals.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		return 2
+	}
+
+	var certbotPath string
+	if *dryRun {
+		if certbotPath, err = certbot.ValidateCertbotPath(cfg.CertbotPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Certbot path validation failed: %v\n", err)
+			return 2
+		}
+	}
+
+	exitCode := 0
+	plans := make([]certificatePlan, 0, len(cfg.Certificates))
+	for i, cert := range cfg.Certificates {
+		plan, argv := planCertificate(i, cert, cfg.Globals)
+		if plan.Error != "" {
+			exitCode = 1
+		} else if *dryRun {
+			plan.DryRun = &dryRunResult{Passed: true}
+			if err := certbot.DryRun(certbotPath, argv); err != nil {
+				plan.DryRun = &dryRunResult{Error: err.Error()}
+				exitCode = 1
+			}
+		}
+		plans = append(plans, plan)
+	}
+
+	if *output == outputJSON {
+		if err := writeJSON(plans); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode plan: %v\n", err)
+			return 1
+		}
+	} else {
+		printPlans(plans)
+	}
+	return exitCode
+}
+
+// planCertificate builds the certificate's arguments and labels every setting they were built from.
+// It also returns the unmasked arguments, which are only passed to certbot.
+func planCertificate(index int, cert config.Certificate, globals config.Globals) (certificatePlan, []string) {
+	plan := certificatePlan{Index: index, Domains: cert.Domains}
+	groups, err := certbot.NewArgsBuilder(cert, globals).Plan()
+	if err != nil {
+		plan.Error = err.Error()
+		return plan, nil
+	}
+
+	settings := cert.Settings(globals)
+	var argv []string
+	for _, group := range groups {
+		argv = append(argv, group.Args...)
+		step := planStep{Step: group.Step, Args: flags.MaskArgs(group.Args), Settings: []planSetting{}}
+		if step.Args == nil {
+			step.Args = []string{}
+		}
+		for _, key := range group.Settings {
+			resolution := settings[key]
+			step.Settings = append(step.Settings, planSetting{Key: key, Value: resolution.Value, Source: resolution.Source})
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	plan.Command = flags.MaskArgs(argv)
+	return plan, argv
+}
+
+// printPlans prints a human-readable plan.
+func printPlans(plans []certificatePlan) {
+	if len(plans) == 0 {
+		fmt.Println("No [[certificate]] blocks found.")
+	}
+	for i, plan := range plans {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Certificate #%d %v\n", plan.Index+1, plan.Domains)
+		if plan.Error != "" {
+			fmt.Printf("  error: %s\n", plan.Error)
+			continue
+		}
+		fmt.Printf("  certbot %s\n", strings.Join(plan.Command, " "))
+		for _, step := range plan.Steps {
+			args := strings.Join(step.Args, " ")
+			if args == "" {
+				args = "(none)"
+			}
+			fmt.Printf("    %-28s %s\n", step.Step, args)
+			for _, setting := range step.Settings {
+				value, source := setting.Value, setting.Source
+				if source == "" {
+					value, source = "unset", "none"
+				}
+				fmt.Printf("      %s = %s [%s]\n", setting.Key, value, source)
+			}
+		}
+		if plan.DryRun != nil {
+			if plan.DryRun.Passed {
+				fmt.Println("  dry run: passed")
+			} else {
+				fmt.Printf("  dry run: failed: %s\n", plan.DryRun.Error)
+			}
+		}
+	}
+}
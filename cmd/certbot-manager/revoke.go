@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/state"
+)
+
+func init() {
+	registerCommand("revoke", "Revoke a managed certificate", runRevoke)
+}
+
+// runRevoke revokes a managed lineage with `certbot revoke`, against the ACME server the manager uses for it.
+// The action is recorded in the manager state.
+func runRevoke(args []string) int {
+	fs := newCommandFlags("revoke", "revoke <cert-name|domain> [--reason reason] [--delete-after-revoke] [--yes]")
+	config.AddFlags(fs)
+	reason := fs.String("reason", "unspecified", fmt.Sprintf("Revocation reason (%v)", certbot.RevokeReasons))
+	deleteAfter := fs.Bool("delete-after-revoke", false, "Also delete the lineage once revoked")
+	yes := fs.BoolP("yes", "y", false, "Don't ask for confirmation")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if !slices.Contains(certbot.RevokeReasons, *reason) {
+		fmt.Fprintf(os.Stderr, "unknown revocation reason '%s' (options: %v)\n", *reason, certbot.RevokeReasons)
+		return 2
+	}
+
+	cfg, certbotPath, st, ok := loadLineageCommand(fs)
+	if !ok {
+		return 2
+	}
+	target, err := resolveTarget(cfg, st, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	serverArgs, err := target.serverArgs(cfg.Globals)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	name, domains := target.lineage.Name, target.domains()
+	if !*yes && !confirm(fmt.Sprintf("Revoke certificate '%s' (%v) with reason '%s'?", name, domains, *reason)) {
+		fmt.Fprintln(os.Stderr, "Aborted.")
+		return 1
+	}
+
+	err = target.restoreRenewalConf()
+	if err == nil {
+		err = certbot.RevokeLineage(context.Background(), certbotPath, cfg.Globals, name, serverArgs, *reason, *deleteAfter, history.TriggerManual)
+	}
+	action := state.Action{Action: "revoke", Lineage: name, Domains: domains, Reason: *reason}
+	saveErr := state.Update(cfg.Globals.StateDir, func(st *state.State) error {
+		st.RecordAction(action, err)
+		if err == nil && *deleteAfter {
+			delete(st.Lineages, name)
+		}
+		return nil
+	})
+	if saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record the revocation: %v\n", saveErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to revoke certificate '%s': %v\n", name, err)
+		return 1
+	}
+
+	fmt.Printf("Revoked certificate '%s'.\n", name)
+	if target.cert != nil {
+		warnStillConfigured(name, *deleteAfter)
+	}
+	return 0
+}
+
+// warnStillConfigured tells what the manager will do with a revoked or deleted lineage that is still configured.
+func warnStillConfigured(name string, deleted bool) {
+	if deleted {
+		fmt.Printf("'%s' is still configured: the manager requests a new certificate on its next start or reload "+
+			"unless it is removed from the configuration.\n", name)
+	} else {
+		fmt.Printf("'%s' is still configured and keeps its revoked certificate until it is renewed. "+
+			"Delete it to get a new certificate on the manager's next start or reload.\n", name)
+	}
+}
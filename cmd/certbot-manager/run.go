@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/state"
+	"certbot-manager/internal/tracing"
+	"certbot-manager/internal/watchdog"
+)
+
+func init() {
+	registerCommand("run", "Run the manager, or a single request and renewal pass with --once", runRun)
+}
+
+// Exit codes of `run --once`, for external schedulers.
+const (
+	exitOK          = 0 // Nothing was issued or renewed, and every run succeeded
+	exitFailure     = 1 // At least one certificate request or the renewal pass failed
+	exitConfigError = 2 // Invalid flags or configuration, or certbot can't be run at all
+	exitRenewed     = 3 // Every run succeeded and at least one certificate was issued or renewed
+)
+
+// runRun runs the long-lived manager, like running certbot-manager without a command, or with --once a single
+// pass for external schedulers such as a Kubernetes CronJob or a systemd timer.
+func runRun(args []string) int {
+	fs := newCommandFlags("run", "run [-c config.toml] [--once]")
+	config.AddFlags(fs)
+	once := fs.Bool("once", false, "Request the certificates, run one renewal pass and exit instead of scheduling renewals")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+
+	if !*once {
+		runManager(cfg)
+		return exitOK
+	}
+	return runOnce(cfg)
+}
+
+// runOnce reconciles lineages, requests the configured certificates and runs `certbot renew`, which also runs the
+// deploy hooks of renewed certificates, then runs the expiry watchdog once unless expiry_check_cron is empty.
+// renewal_cron is not used. Lineages whose certificate serial changed during the pass count as renewed.
+func runOnce(cfg *config.Config) int {
+	if err := logging.Configure(cfg.LogLevel, cfg.Globals.LogFormat, cfg.Logging); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logging: %v\n", err)
+		return exitConfigError
+	}
+
+	logrus.Infof("Starting Certbot Manager %s (single run)...", currentBuildInfo())
+	audit.Configure(cfg.Audit, "run")
+	auditConfigLoad(audit.ActionConfigLoad, history.TriggerManual, cfg, nil, nil)
+
+	if err := tracing.Configure(cfg.Tracing, currentBuildInfo().Version); err != nil {
+		logrus.Errorf("Failed to setup tracing: %v", err)
+		return exitConfigError
+	}
+	defer tracing.Shutdown()
+
+	certbotPath, err := certbot.ValidateCertbotPath(cfg.CertbotPath)
+	if err != nil {
+		logrus.Errorf("Certbot path validation failed: %v", err)
+		return exitConfigError
+	}
+	logrus.Infof("Using certbot %s", detectCertbot(certbotPath))
+
+	if _, err := state.Open(cfg.Globals.StateDir); err != nil {
+		logrus.Errorf("Failed to open manager state: %v", err)
+		return exitConfigError
+	}
+
+	if len(cfg.Certificates) == 0 {
+		logrus.Info("No [[certificate]] blocks found in configuration. Nothing to do.")
+		return exitOK
+	}
+
+	before, err := letsencrypt.Serials(cfg.Globals.ConfigDir)
+	if err != nil {
+		logrus.Errorf("Failed to read lineages: %v", err)
+		return exitFailure
+	}
+
+	ctx, span := startJob(history.TriggerManual)
+	ok := processCertificates(ctx, cfg, certbotPath, history.TriggerManual)
+	if !ok {
+		logrus.Error("One or more certificate requests failed. Check logs above for details.")
+	}
+
+	renewErr := certbot.RenewCertificates(ctx, certbotPath, cfg, history.TriggerManual)
+	if ok && renewErr == nil {
+		tracing.End(span, nil, "")
+	} else {
+		tracing.End(span, tracing.ErrFailed, "")
+	}
+	if err := certbot.RecordRenewal(cfg.Globals.StateDir, renewErr); err != nil {
+		logrus.Errorf("Failed to record renewal result: %v", err)
+	}
+	ok = ok && renewErr == nil
+
+	if cfg.Globals.ExpiryCheckCron != "" {
+		watchdog.New().Run(cfg.Globals)
+	}
+
+	after, err := letsencrypt.Serials(cfg.Globals.ConfigDir)
+	if err != nil {
+		logrus.Errorf("Failed to read lineages: %v", err)
+		return exitFailure
+	}
+	var renewed []string
+	for name, serial := range after {
+		if before[name] != serial {
+			renewed = append(renewed, name)
+		}
+	}
+	sort.Strings(renewed)
+	var runErr error
+	if !ok {
+		runErr = tracing.ErrFailed
+	}
+	audit.Record(audit.Event{Action: audit.ActionRun, Trigger: history.TriggerManual, Details: map[string]any{"renewed_or_issued": renewed}}, runErr)
+
+	switch {
+	case !ok:
+		logrus.Errorf("Single run finished with failures (renewed or issued: %v).", renewed)
+		return exitFailure
+	case len(renewed) > 0:
+		logrus.Infof("Single run finished. Renewed or issued: %v.", renewed)
+		return exitRenewed
+	default:
+		logrus.Info("Single run finished. Nothing to renew.")
+		return exitOK
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/certbot/authenticators"
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/tracing"
+)
+
+func init() {
+	registerCommand("schema", "Print the JSON Schema of the configuration file", runSchema)
+}
+
+// runSchema prints the configuration JSON Schema, with enums taken from the packages that validate them.
+func runSchema(args []string) int {
+	fs := newCommandFlags("schema", "schema [--output file]")
+	output := fs.StringP("output", "o", "", "Write the schema to this file instead of stdout")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+
+	schema := config.GenerateSchema(map[string][]string{
+		"cmd":           certbot.Commands(),
+		"authenticator": authenticators.Names(),
+		"key_type":      flags.KeyTypes,
+		"args_mode":     config.ArgsModes,
+		"orphan_policy": config.OrphanPolicies,
+		"log_format":    logging.Formats,
+		"format":        logging.Formats,
+		"level":         logging.Levels(),
+		"log_level":     logging.Levels(),
+		"network":       logging.SyslogNetworks,
+		"facility":      logging.Facilities,
+		"exporter":      tracing.Exporters,
+	})
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode schema: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, _ = os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write schema to '%s': %v\n", *output, err)
+		return 1
+	}
+	return 0
+}
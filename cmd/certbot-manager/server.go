@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/metrics"
+)
+
+// newHTTPHandler routes the requests of the manager's HTTP listener.
+func newHTTPHandler(health *healthState) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", health.handleHealthz)
+	mux.HandleFunc("/readyz", health.handleReadyz)
+	mux.HandleFunc("/admin/log-level", health.handleAdminLogLevel)
+	return mux
+}
+
+// writeJSONResponse writes v as an indented JSON response with the given status code.
+func writeJSONResponse(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		logrus.Debugf("Failed to write HTTP response: %v", err)
+	}
+}
+
+// startHTTPServer serves newHTTPHandler on addr in the background. It returns nil if addr is empty.
+func startHTTPServer(addr string, health *healthState) (*http.Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on '%s': %w", addr, err)
+	}
+
+	server := &http.Server{Handler: newHTTPHandler(health), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("HTTP listener stopped: %v", err)
+		}
+	}()
+	logrus.Infof("Serving /metrics, /healthz, /readyz and /admin/log-level on http://%s", listener.Addr())
+	return server, nil
+}
+
+// stopHTTPServer shuts the listener down, giving in-flight requests a few seconds to finish.
+func stopHTTPServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.Warnf("Failed to stop the HTTP listener: %v", err)
+	}
+}
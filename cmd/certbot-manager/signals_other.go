@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// debugSignals is empty where SIGUSR2 doesn't exist; the admin endpoint changes the level instead.
+var debugSignals []os.Signal
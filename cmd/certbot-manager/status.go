@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/state"
+)
+
+func init() {
+	registerCommand("status", "Inspect the managed certificates and compare them with the configuration", runStatus)
+}
+
+// statusReport is the JSON and YAML output of `certbot-manager status`.
+type statusReport struct {
+	CertbotManager versionReport   `json:"certbot_manager" yaml:"certbot_manager"`
+	Lineages       []lineageStatus `json:"lineages" yaml:"lineages"`
+}
+
+// lineageStatus describes a managed lineage's live certificate and how it differs from its configuration.
+// Configured certificates without a lineage are reported with an empty Name.
+type lineageStatus struct {
+	Name           string         `json:"name" yaml:"name"`
+	Certificate    *int           `json:"certificate" yaml:"certificate"` // Index of the configured certificate, nil if unconfigured
+	Domains        []string       `json:"domains" yaml:"domains"`
+	Issuer         string         `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	StagingCA      bool           `json:"staging_ca" yaml:"staging_ca"`
+	KeyType        string         `json:"key_type,omitempty" yaml:"key_type,omitempty"`
+	KeySize        string         `json:"key_size,omitempty" yaml:"key_size,omitempty"`
+	NotAfter       *time.Time     `json:"not_after,omitempty" yaml:"not_after,omitempty"`
+	DaysRemaining  *int           `json:"days_remaining,omitempty" yaml:"days_remaining,omitempty"`
+	RenewalStopped bool           `json:"renewal_stopped" yaml:"renewal_stopped"`
+	LastRun        *lastRunStatus `json:"last_run,omitempty" yaml:"last_run,omitempty"`
+	Drift          []string       `json:"drift" yaml:"drift"`
+	Error          string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// lastRunStatus is the outcome of the last certbot run recorded for a lineage.
+type lastRunStatus struct {
+	Command   string    `json:"command" yaml:"command"`
+	At        time.Time `json:"at" yaml:"at"`
+	Succeeded bool      `json:"succeeded" yaml:"succeeded"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// runStatus inspects every managed lineage: those recorded in the manager state and those matching a configured
+// certificate. Configured certificates that have no lineage yet are listed as well.
+func runStatus(args []string) int {
+	fs := newCommandFlags("status", "status [-c config.toml] [--output table|json|yaml]")
+	config.AddFlags(fs)
+	output := fs.StringP("output", "o", "table", "Output format (table, json, yaml)")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, "table", outputJSON, outputYAML); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.Globals.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		return 2
+	}
+
+	statuses, err := collectStatus(cfg, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report := statusReport{
+		CertbotManager: versionReport{buildInfo: currentBuildInfo(), Certbot: detectCertbot(cfg.CertbotPath)},
+		Lineages:       statuses,
+	}
+	if report.Lineages == nil {
+		report.Lineages = []lineageStatus{}
+	}
+	switch *output {
+	case outputJSON:
+		err = writeJSON(report)
+	case outputYAML:
+		err = writeYAML(report)
+	default:
+		printStatusTable(statuses)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode status: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// collectStatus matches the lineages in the certbot config directory with the configured certificates.
+// Certificates are listed in configuration order, followed by managed lineages that are no longer configured.
+func collectStatus(cfg *config.Config, now time.Time) ([]lineageStatus, error) {
+	st, err := state.Open(cfg.Globals.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	lineages, err := letsencrypt.Lineages(cfg.Globals.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[string]int)
+	var statuses []lineageStatus
+	for i, cert := range cfg.Certificates {
+		lineage := letsencrypt.MatchLineage(lineages, cert.Domains)
+		if lineage == nil {
+			statuses = append(statuses, lineageStatus{
+				Certificate: &i,
+				Domains:     cert.Domains,
+				Drift:       []string{"no lineage issued yet"},
+			})
+			continue
+		}
+		if _, taken := matched[lineage.Name]; taken {
+			// Another certificate already claimed this lineage; report this one as not issued.
+			statuses = append(statuses, lineageStatus{
+				Certificate: &i,
+				Domains:     cert.Domains,
+				Drift:       []string{fmt.Sprintf("no lineage issued yet (closest match '%s' belongs to another certificate)", lineage.Name)},
+			})
+			continue
+		}
+		matched[lineage.Name] = i
+
+		status := inspectLineage(*lineage, st.Lineages[lineage.Name], now)
+		status.Certificate = &i
+		status.Drift = append(status.Drift, certificateDrift(cert, cfg.Globals, *lineage)...)
+		statuses = append(statuses, status)
+	}
+
+	for _, lineage := range lineages {
+		recorded, managed := st.Lineages[lineage.Name]
+		if _, ok := matched[lineage.Name]; ok || !managed {
+			continue
+		}
+		status := inspectLineage(lineage, recorded, now)
+		status.Drift = append(status.Drift, "not configured (orphaned lineage)")
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// inspectLineage reports what the lineage's live certificate and the manager state say about it.
+func inspectLineage(lineage letsencrypt.Lineage, recorded *state.Lineage, now time.Time) lineageStatus {
+	status := lineageStatus{Name: lineage.Name, Domains: lineage.Domains(), Drift: []string{}}
+	if recorded != nil {
+		status.RenewalStopped = recorded.RenewalStopped
+		if run := recorded.LastRun; run != nil {
+			status.LastRun = &lastRunStatus{Command: run.Command, At: run.At, Succeeded: run.Succeeded, Error: run.Error}
+		}
+	}
+	status.RenewalStopped = status.RenewalStopped || lineage.Disabled
+
+	cert := lineage.Cert
+	switch {
+	case lineage.Err != nil:
+		status.Error = lineage.Err.Error()
+	case cert == nil:
+		status.Error = lineage.CertErr.Error()
+	}
+	if cert == nil {
+		return status
+	}
+	status.Issuer = cert.Issuer.CommonName
+	if status.Issuer == "" {
+		status.Issuer = cert.Issuer.String()
+	}
+	status.StagingCA = letsencrypt.IsStagingIssuer(cert)
+	status.KeyType, status.KeySize = letsencrypt.KeyType(cert)
+	notAfter := cert.NotAfter
+	days := int(notAfter.Sub(now).Hours() / 24)
+	status.NotAfter, status.DaysRemaining = &notAfter, &days
+	return status
+}
+
+// certificateDrift lists the differences between a configured certificate and its lineage.
+func certificateDrift(cert config.Certificate, globals config.Globals, lineage letsencrypt.Lineage) []string {
+	var drift []string
+	if lineage.Cert != nil {
+		issued := make(map[string]bool)
+		for _, domain := range lineage.Domains() {
+			issued[strings.ToLower(domain)] = true
+		}
+		configured := make(map[string]bool)
+		var missing, extra []string
+		for _, domain := range cert.Domains {
+			domain = strings.ToLower(strings.TrimSpace(domain))
+			configured[domain] = true
+			if !issued[domain] {
+				missing = append(missing, domain)
+			}
+		}
+		for _, domain := range lineage.Domains() {
+			if !configured[strings.ToLower(domain)] {
+				extra = append(extra, domain)
+			}
+		}
+		if len(missing) > 0 {
+			drift = append(drift, fmt.Sprintf("missing domains: %s", strings.Join(missing, ", ")))
+		}
+		if len(extra) > 0 {
+			drift = append(drift, fmt.Sprintf("extra domains: %s", strings.Join(extra, ", ")))
+		}
+
+		keyType, _ := letsencrypt.KeyType(lineage.Cert)
+		if wanted := flags.ResolveString(cert.KeyType, globals.KeyType); wanted != "" && wanted != keyType {
+			drift = append(drift, fmt.Sprintf("key type is %s, configured %s", keyType, wanted))
+		}
+	}
+
+	if server := flags.ResolveString(cert.Server, globals.Server); server != "" {
+		if issuedBy := lineage.Renewal.Server(); issuedBy != server {
+			drift = append(drift, fmt.Sprintf("issued by %s, configured %s", issuedBy, server))
+		}
+		return drift
+	}
+	wantStaging := false
+	if staging := flags.ResolveBoolPtr(cert.Staging, globals.Staging); staging != nil {
+		wantStaging = *staging
+	}
+	isStaging := lineage.Renewal.IsStaging() || (lineage.Cert != nil && letsencrypt.IsStagingIssuer(lineage.Cert))
+	if isStaging != wantStaging {
+		drift = append(drift, fmt.Sprintf("issued by %s, configured %s", environmentName(isStaging), environmentName(wantStaging)))
+	}
+	return drift
+}
+
+// environmentName names the ACME environment for drift messages.
+func environmentName(staging bool) string {
+	if staging {
+		return "staging"
+	}
+	return "production"
+}
+
+// printStatusTable prints one row per lineage.
+func printStatusTable(statuses []lineageStatus) {
+	if len(statuses) == 0 {
+		fmt.Println("No managed certificates found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDOMAINS\tISSUER\tKEY\tNOT AFTER\tDAYS\tLAST RUN\tDRIFT")
+	for _, s := range statuses {
+		name := s.Name
+		if name == "" {
+			name = "-"
+		}
+		issuer := s.Issuer
+		if s.StagingCA {
+			issuer += " [staging]"
+		}
+		key := strings.TrimSpace(s.KeyType + " " + s.KeySize)
+		notAfter, days := "-", "-"
+		if s.NotAfter != nil {
+			notAfter = s.NotAfter.Format("2006-01-02")
+			days = fmt.Sprint(*s.DaysRemaining)
+		}
+		lastRun := "-"
+		if s.LastRun != nil {
+			result := "ok"
+			if !s.LastRun.Succeeded {
+				result = "failed"
+			}
+			lastRun = fmt.Sprintf("%s %s %s", s.LastRun.Command, result, s.LastRun.At.Local().Format("2006-01-02 15:04"))
+		}
+		if s.RenewalStopped {
+			lastRun += " (renewal stopped)"
+		}
+		drift := strings.Join(s.Drift, "; ")
+		if s.Error != "" {
+			drift = strings.TrimPrefix(drift+"; "+s.Error, "; ")
+		}
+		if drift == "" {
+			drift = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			name, strings.Join(s.Domains, ","), orDash(issuer), orDash(key), notAfter, days, lastRun, drift)
+	}
+	w.Flush()
+}
+
+// orDash returns s, or "-" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/state"
+)
+
+// lineageTarget is the managed lineage a command such as `revoke` or `delete` acts on.
+type lineageTarget struct {
+	lineage  letsencrypt.Lineage
+	cert     *config.Certificate // Configured certificate of the lineage, nil if it is no longer configured
+	recorded *state.Lineage      // Manager state of the lineage, nil if it wasn't recorded yet
+}
+
+// resolveTarget finds the managed lineage named by arg, which is either a lineage name or one of its domains.
+// Lineages that neither match a configured certificate nor are recorded in the manager state are not managed by
+// certbot-manager and are refused.
+func resolveTarget(cfg *config.Config, st *state.State, arg string) (*lineageTarget, error) {
+	lineages, err := letsencrypt.Lineages(cfg.Globals.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Which configured certificate each lineage belongs to.
+	configured := make(map[string]*config.Certificate)
+	for i := range cfg.Certificates {
+		if lineage := letsencrypt.MatchLineage(lineages, cfg.Certificates[i].Domains); lineage != nil {
+			if _, taken := configured[lineage.Name]; !taken {
+				configured[lineage.Name] = &cfg.Certificates[i]
+			}
+		}
+	}
+
+	var match *letsencrypt.Lineage
+	for i := range lineages {
+		if lineages[i].Name == arg {
+			match = &lineages[i]
+			break
+		}
+	}
+	if match == nil {
+		for i := range lineages {
+			for _, domain := range lineages[i].Domains() {
+				if strings.EqualFold(domain, arg) {
+					if match != nil {
+						return nil, fmt.Errorf("domain '%s' is covered by several lineages ('%s', '%s'); use the lineage name", arg, match.Name, lineages[i].Name)
+					}
+					match = &lineages[i]
+				}
+			}
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no lineage named '%s' or covering it found in %s", arg, cfg.Globals.ConfigDir)
+	}
+
+	target := &lineageTarget{lineage: *match, cert: configured[match.Name], recorded: st.Lineages[match.Name]}
+	if target.cert == nil && target.recorded == nil {
+		return nil, fmt.Errorf("lineage '%s' is not managed by certbot-manager", match.Name)
+	}
+	return target, nil
+}
+
+// domains returns the domains of the target lineage, falling back to the configured or recorded ones
+// if its certificate can't be read.
+func (t *lineageTarget) domains() []string {
+	if domains := t.lineage.Domains(); domains != nil {
+		return domains
+	}
+	if t.cert != nil {
+		return t.cert.Domains
+	}
+	if t.recorded != nil {
+		return t.recorded.Domains
+	}
+	return nil
+}
+
+// serverArgs returns the arguments selecting the ACME server of the target: the staging setting the manager
+// requests it with if it is configured, otherwise the server recorded when it was issued.
+func (t *lineageTarget) serverArgs(globals config.Globals) ([]string, error) {
+	if t.cert != nil {
+		return certbot.ServerArgs(*t.cert, globals)
+	}
+	if t.recorded != nil && t.recorded.Server != "" {
+		return certbot.RecordedServerArgs(t.recorded.Server), nil
+	}
+	return certbot.RecordedServerArgs(t.lineage.Renewal.Server()), nil
+}
+
+// restoreRenewalConf resumes the renewal of a lineage whose renewal was stopped by the orphan policy:
+// certbot only finds lineages through their renewal config.
+func (t *lineageTarget) restoreRenewalConf() error {
+	if err := letsencrypt.ResumeRenewal(t.lineage); err != nil {
+		return fmt.Errorf("failed to restore renewal config of lineage '%s': %w", t.lineage.Name, err)
+	}
+	if t.recorded != nil {
+		t.recorded.RenewalStopped = false
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal. Anything but "y" or "yes", including end of input, is a no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadLineageCommand loads the configuration, validates the certbot executable and opens the manager state for a
+// command acting on a lineage. On failure it prints the error and returns false; the exit code is then 2.
+func loadLineageCommand(fs *pflag.FlagSet) (*config.Config, string, *state.State, bool) {
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return nil, "", nil, false
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.Globals.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		return nil, "", nil, false
+	}
+	audit.Configure(cfg.Audit, fs.Name())
+	certbotPath, err := certbot.ValidateCertbotPath(cfg.CertbotPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Certbot path validation failed: %v\n", err)
+		return nil, "", nil, false
+	}
+	st, err := state.Open(cfg.Globals.StateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open manager state: %v\n", err)
+		return nil, "", nil, false
+	}
+	return cfg, certbotPath, st, true
+}
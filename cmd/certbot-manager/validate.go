@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	cronpkg "certbot-manager/internal/cron"
+	"certbot-manager/internal/logging"
+)
+
+func init() {
+	registerCommand("validate", "Validate the configuration without running certbot", runValidate)
+}
+
+// Severities of validation diagnostics.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// diagnostic is a single validation finding. Scope names the part of the configuration it is about,
+// e.g. "config", "globals.renewal_cron" or "certificate[1]".
+type diagnostic struct {
+	Severity string `json:"severity"`
+	Scope    string `json:"scope"`
+	Message  string `json:"message"`
+}
+
+// validationReport is the result of `certbot-manager validate`, printed as text or JSON.
+type validationReport struct {
+	Valid        bool                `json:"valid"`
+	ConfigFile   string              `json:"config_file"`
+	Certificates []certificateReport `json:"certificates"`
+	Cron         *cronReport         `json:"cron,omitempty"`
+	Diagnostics  []diagnostic        `json:"diagnostics"`
+}
+
+// certificateReport holds the certbot argv built for a certificate, with secrets masked.
+type certificateReport struct {
+	Index   int      `json:"index"`
+	Domains []string `json:"domains"`
+	Argv    []string `json:"argv,omitempty"`
+}
+
+// cronReport holds the renewal schedule and its next fire times.
+type cronReport struct {
+	Expression string      `json:"expression"`
+	NextRuns   []time.Time `json:"next_runs"`
+}
+
+func (r *validationReport) add(severity, scope, format string, args ...any) {
+	r.Diagnostics = append(r.Diagnostics, diagnostic{Severity: severity, Scope: scope, Message: fmt.Sprintf(format, args...)})
+	if severity == severityError {
+		r.Valid = false
+	}
+}
+
+// runValidate loads and validates the configuration, builds every certificate's certbot arguments and
+// checks the renewal schedule. It exits with 1 if any error was found.
+func runValidate(args []string) int {
+	fs := newCommandFlags("validate", "validate [-c config.toml] [--output text|json]")
+	config.AddFlags(fs)
+	output := fs.StringP("output", "o", outputText, "Output format (text, json)")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, outputText, outputJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := validate(fs)
+
+	if *output == outputJSON {
+		if err := writeJSON(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode report: %v\n", err)
+			return 1
+		}
+	} else {
+		printValidationReport(report)
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// validate runs every check and collects the findings in a report.
+func validate(fs *pflag.FlagSet) *validationReport {
+	configFile, _ := fs.GetString("config")
+	report := &validationReport{Valid: true, ConfigFile: configFile, Certificates: []certificateReport{}, Diagnostics: []diagnostic{}}
+
+	cfg, err := config.LoadFrom(fs)
+	if err != nil {
+		report.add(severityError, "config", "%v", err)
+		return report
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.Globals.LogFormat); err != nil {
+		report.add(severityWarning, "logging", "%v", err)
+	}
+	if cfg.LogLevelOverride != "" {
+		report.add(severityWarning, "globals.log_level", "'%s' is overridden by %s, which sets '%s'",
+			cfg.Globals.LogLevel, cfg.LogLevelOverride, cfg.LogLevel)
+	}
+
+	if _, err := certbot.ValidateCertbotPath(cfg.CertbotPath); err != nil {
+		report.add(severityWarning, "certbot_path", "%v", err)
+	}
+
+	if len(cfg.Certificates) == 0 {
+		report.add(severityWarning, "certificate", "no [[certificate]] blocks found")
+	}
+	for i, cert := range cfg.Certificates {
+		scope := fmt.Sprintf("certificate[%d]", i)
+		certReport := certificateReport{Index: i, Domains: cert.Domains}
+		argv, err := certbot.NewArgsBuilder(cert, cfg.Globals).Build()
+		if err != nil {
+			report.add(severityError, scope, "%v", err)
+		} else {
+			certReport.Argv = flags.MaskArgs(argv)
+		}
+		report.Certificates = append(report.Certificates, certReport)
+	}
+
+	if cfg.Globals.RenewalCron == "" {
+		report.add(severityWarning, "globals.renewal_cron", "not set; the manager can only run with 'run --once'")
+		return report
+	}
+	runs, err := cronpkg.NextRuns(cfg.Globals.RenewalCron, time.Now(), 5)
+	if err != nil {
+		report.add(severityError, "globals.renewal_cron", "%v", err)
+	} else {
+		report.Cron = &cronReport{Expression: cfg.Globals.RenewalCron, NextRuns: runs}
+	}
+
+	return report
+}
+
+// printValidationReport prints a human-readable report.
+func printValidationReport(report *validationReport) {
+	fmt.Printf("Config file: %s\n", report.ConfigFile)
+
+	for _, cert := range report.Certificates {
+		fmt.Printf("\nCertificate #%d %v\n", cert.Index+1, cert.Domains)
+		if cert.Argv != nil {
+			fmt.Printf("  certbot %s\n", strings.Join(cert.Argv, " "))
+		}
+	}
+
+	if report.Cron != nil {
+		fmt.Printf("\nRenewal schedule: %s\n", report.Cron.Expression)
+		for _, run := range report.Cron.NextRuns {
+			fmt.Printf("  %s\n", run.Format(time.RFC3339))
+		}
+	}
+
+	if len(report.Diagnostics) > 0 {
+		fmt.Println("\nDiagnostics:")
+		for _, d := range report.Diagnostics {
+			fmt.Printf("  [%s] %s: %s\n", d.Severity, d.Scope, d.Message)
+		}
+	}
+
+	if report.Valid {
+		fmt.Println("\nConfiguration is valid.")
+	} else {
+		fmt.Println("\nConfiguration is invalid.")
+	}
+}
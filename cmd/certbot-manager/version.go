@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"certbot-manager/internal/certbot"
+	"certbot-manager/internal/config"
+)
+
+// Build information, injected at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=<sha> -X main.date=<RFC 3339 time>".
+// Values left empty are taken from the build info Go embeds in the binary, when available.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+func init() {
+	registerCommand("version", "Print version, build and certbot information", runVersion)
+	registerAlias("--version", "version")
+}
+
+// buildInfo describes the running binary.
+type buildInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty" yaml:"build_date,omitempty"`
+	GoVersion string `json:"go_version" yaml:"go_version"`
+	Platform  string `json:"platform" yaml:"platform"`
+}
+
+// certbotInfo describes the certbot installation the manager runs.
+type certbotInfo struct {
+	Path    string   `json:"path" yaml:"path"`
+	Version string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Plugins []string `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// versionReport is the output of `certbot-manager version`, also embedded in status output.
+type versionReport struct {
+	buildInfo `yaml:",inline"`
+	Certbot   *certbotInfo `json:"certbot,omitempty" yaml:"certbot,omitempty"`
+}
+
+// String renders the build information on one line, e.g. "v1.2.3 (commit 0a1b2c3, built 2025-01-01T00:00:00Z, go1.24.1 linux/amd64)".
+func (b buildInfo) String() string {
+	details := []string{}
+	if b.Commit != "" {
+		details = append(details, "commit "+shortCommit(b.Commit))
+	}
+	if b.BuildDate != "" {
+		details = append(details, "built "+b.BuildDate)
+	}
+	details = append(details, b.GoVersion+" "+b.Platform)
+	return fmt.Sprintf("%s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+// String renders the certbot information on one line.
+func (c certbotInfo) String() string {
+	if c.Error != "" {
+		return fmt.Sprintf("%s (%s)", c.Path, c.Error)
+	}
+	return fmt.Sprintf("%s at %s (plugins: %s)", c.Version, c.Path, strings.Join(c.Plugins, ", "))
+}
+
+// runVersion prints the build information and the detected certbot version and plugins.
+func runVersion(args []string) int {
+	fs := newCommandFlags("version", "version [--certbot-path certbot] [--output text|json]")
+	certbotPath := fs.String("certbot-path", config.Defaults.CertbotPath, "Path to the certbot executable")
+	output := fs.StringP("output", "o", outputText, "Output format (text, json)")
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := checkOutputFormat(*output, outputText, outputJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := versionReport{buildInfo: currentBuildInfo(), Certbot: detectCertbot(*certbotPath)}
+	if *output == outputJSON {
+		if err := writeJSON(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode version: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Printf("certbot-manager %s\n", report.buildInfo)
+	fmt.Printf("certbot: %s\n", report.Certbot)
+	return 0
+}
+
+// currentBuildInfo returns the injected build information, completed from the build info embedded by Go.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && embedded.Main.Version != "(devel)" {
+			info.Version = embedded.Main.Version
+		}
+		modified := false
+		for _, setting := range embedded.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// detectCertbot reports the version and plugins of the certbot executable, or why they couldn't be detected.
+func detectCertbot(certbotPath string) *certbotInfo {
+	info := &certbotInfo{Path: certbotPath}
+	if resolved, err := exec.LookPath(certbotPath); err == nil {
+		info.Path = resolved
+	}
+
+	var err error
+	if info.Version, err = certbot.Version(info.Path); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	if info.Plugins, err = certbot.Plugins(info.Path); err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// shortCommit abbreviates a git commit hash for log lines, keeping a "-dirty" suffix.
+func shortCommit(commit string) string {
+	hash, suffix, _ := strings.Cut(commit, "-")
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	if suffix != "" {
+		return hash + "-" + suffix
+	}
+	return hash
+}
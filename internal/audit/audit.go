@@ -0,0 +1,268 @@
+// Package audit appends a record of every administrative action, configuration change, issuance and hook execution
+// to an append-only JSON lines file, separate from the operational logs. With hash_chain, every event carries the
+// SHA-256 hash of its own line and of the previous event's, so edited, removed or reordered events can be detected
+// by Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"certbot-manager/internal/logging"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionConfigLoad   = "config.load"        // The manager or a command loaded the configuration
+	ActionConfigReload = "config.reload"      // The daemon reloaded the configuration
+	ActionRun          = "run"                // A manual `run --once`: certificate requests and renewal
+	ActionIssue        = "certificate.issue"  // A certbot run left a lineage with a new certificate
+	ActionRevoke       = "certificate.revoke" // A lineage was revoked, manually or by orphan_policy
+	ActionDelete       = "certificate.delete" // A lineage was deleted, manually or by orphan_policy
+	ActionHook         = "hook.run"           // certbot ran a pre, post or deploy hook
+	ActionLogLevel     = "logging.level"      // The log level or overrides were changed at runtime
+)
+
+// Outcomes of an event.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Config is the [audit] table of the configuration file.
+type Config struct {
+	// Audit log file (JSON lines); auditing is off when empty
+	Path string `mapstructure:"path"`
+	// Chain every event to the previous one by its SHA-256 hash, so tampering can be detected with verify-audit
+	HashChain bool `mapstructure:"hash_chain"`
+}
+
+// Event is one line of the audit log.
+type Event struct {
+	Time        time.Time      `json:"time"`
+	Action      string         `json:"action"`
+	Outcome     string         `json:"outcome"`
+	Actor       Actor          `json:"actor"`
+	Trigger     string         `json:"trigger,omitempty"` // What started the action: startup, cron, reload or manual
+	RunID       string         `json:"run_id,omitempty"`
+	Cert        string         `json:"cert,omitempty"`
+	Domains     []string       `json:"domains,omitempty"`
+	Serial      string         `json:"serial,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"` // SHA-256 of the DER certificate, in hex
+	Error       string         `json:"error,omitempty"`
+	Details     map[string]any `json:"details,omitempty"`
+	PrevHash    string         `json:"prev_hash,omitempty"` // Hash of the previous event, with hash_chain
+}
+
+// Actor is the process that performed an action, and who started it.
+type Actor struct {
+	Command  string `json:"command"`             // "daemon", or the subcommand, e.g. revoke
+	User     string `json:"user"`                // OS user the process runs as
+	SudoUser string `json:"sudo_user,omitempty"` // User who ran the command through sudo
+	Host     string `json:"host"`
+	PID      int    `json:"pid"`
+}
+
+// current holds the configuration and actor of the last Configure.
+var current struct {
+	sync.Mutex
+	cfg   Config
+	actor Actor
+}
+
+// Configure sets the audit log of the events recorded by this process, and the command they are attributed to.
+// It can be called again on reload.
+func Configure(cfg Config, command string) {
+	actor := Actor{Command: command, SudoUser: os.Getenv("SUDO_USER"), PID: os.Getpid()}
+	if u, err := user.Current(); err == nil {
+		actor.User = u.Username
+	}
+	actor.Host, _ = os.Hostname()
+
+	current.Lock()
+	current.cfg, current.actor = cfg, actor
+	current.Unlock()
+}
+
+// Record appends e to the audit log, if one is configured. err is the outcome of the action. Failures are logged:
+// the audit log must never fail an action.
+func Record(e Event, err error) {
+	current.Lock()
+	defer current.Unlock()
+	if current.cfg.Path == "" {
+		return
+	}
+	e.Time, e.Actor, e.Outcome = time.Now().UTC(), current.actor, OutcomeSuccess
+	if err != nil {
+		e.Outcome, e.Error = OutcomeFailure, err.Error()
+	}
+	if err := appendEvent(current.cfg, e); err != nil {
+		logging.Component("audit").Errorf("Failed to record audit event '%s': %v", e.Action, err)
+	}
+}
+
+// appendEvent writes e as one line, chained to the last line of the file with cfg.HashChain. The file is locked
+// while it is read and written, so the daemon and commands can record events concurrently.
+func appendEvent(cfg Config, e Event) error {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create audit log directory '%s': %w", filepath.Dir(cfg.Path), err)
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log '%s': %w", cfg.Path, err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("failed to lock audit log '%s': %w", cfg.Path, err)
+	}
+
+	if cfg.HashChain {
+		if e.PrevHash, err = lastHash(file); err != nil {
+			return fmt.Errorf("failed to read audit log '%s': %w", cfg.Path, err)
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	if cfg.HashChain {
+		line = appendHash(line)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log '%s': %w", cfg.Path, err)
+	}
+	return file.Sync()
+}
+
+// A chained line ends with its hash as the last member: `,"hash":"<64 hex digits>"}`.
+const (
+	hashPrefix    = `,"hash":"`
+	hashSuffixLen = len(hashPrefix) + sha256.Size*2 + len(`"}`)
+)
+
+// appendHash adds the hash of line, a JSON object, as its last member.
+func appendHash(line []byte) []byte {
+	sum := sha256.Sum256(line)
+	chained := append(line[:len(line)-1:len(line)-1], hashPrefix...)
+	chained = append(chained, hex.EncodeToString(sum[:])...)
+	return append(chained, `"}`...)
+}
+
+// splitHash returns the line as it was hashed, and the hash it carries. ok is false if the line isn't chained.
+func splitHash(line []byte) (body []byte, hash string, ok bool) {
+	if len(line) < hashSuffixLen || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", false
+	}
+	suffix := line[len(line)-hashSuffixLen:]
+	if !bytes.HasPrefix(suffix, []byte(hashPrefix)) {
+		return nil, "", false
+	}
+	hash = string(suffix[len(hashPrefix) : len(suffix)-2])
+	if _, err := hex.DecodeString(hash); err != nil {
+		return nil, "", false
+	}
+	body = append(line[:len(line)-hashSuffixLen:len(line)-hashSuffixLen], '}')
+	return body, hash, true
+}
+
+// maxLineBytes bounds the lines read from the audit log.
+const maxLineBytes = 1024 * 1024
+
+// lastHash returns the hash of the last line of file, empty if the file is empty or its last line isn't chained.
+func lastHash(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := min(info.Size(), maxLineBytes)
+	if size == 0 {
+		return "", nil
+	}
+	buf := make([]byte, size)
+	if _, err := file.ReadAt(buf, info.Size()-size); err != nil {
+		return "", err
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	_, hash, _ := splitHash(buf[bytes.LastIndexByte(buf, '\n')+1:])
+	return hash, nil
+}
+
+// Problem is an audit log line that failed verification.
+type Problem struct {
+	Line  int    `json:"line" yaml:"line"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// Report is the outcome of Verify.
+type Report struct {
+	Path     string    `json:"path" yaml:"path"`
+	Events   int       `json:"events" yaml:"events"`
+	Chained  int       `json:"chained" yaml:"chained"` // Events carrying a hash
+	Problems []Problem `json:"problems" yaml:"problems"`
+}
+
+// Verify checks the hash chain of the audit log at path: every chained line must match its hash and name the hash
+// of the chained line before it. Lines written before hash_chain was enabled are counted but not checked.
+// Events removed from the end of the file can't be detected; ship the log elsewhere to cover that.
+func Verify(path string) (*Report, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("audit log '%s' not found", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	report := &Report{Path: path, Problems: []Problem{}}
+	problem := func(line int, format string, args ...any) {
+		report.Problems = append(report.Problems, Problem{Line: line, Error: fmt.Sprintf(format, args...)})
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	previous := ""
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		report.Events++
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			problem(n, "not a JSON event: %v", err)
+			continue
+		}
+		body, hash, ok := splitHash(line)
+		if !ok {
+			if report.Chained > 0 {
+				problem(n, "event isn't chained, but earlier events are: it was inserted or its hash removed")
+			}
+			continue
+		}
+		report.Chained++
+		if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != hash {
+			problem(n, "hash mismatch: the event was modified")
+		}
+		switch {
+		case report.Chained == 1 && e.PrevHash != "":
+			problem(n, "the first chained event follows a missing one: earlier events were removed")
+		case report.Chained > 1 && e.PrevHash != previous:
+			problem(n, "prev_hash doesn't match the previous event: events were removed, inserted or reordered")
+		}
+		previous = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log '%s': %w", path, err)
+	}
+	return report, nil
+}
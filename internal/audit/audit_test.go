@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSplitHash(t *testing.T) {
+	body := []byte(`{"action":"run","outcome":"success"}`)
+	chained := appendHash(body)
+	got, hash, ok := splitHash(chained)
+	if !ok || !bytes.Equal(got, body) || len(hash) != 64 {
+		t.Fatalf("splitHash(appendHash(%s)) = %s, %q, %v", body, got, hash, ok)
+	}
+	var e map[string]any
+	if err := json.Unmarshal(chained, &e); err != nil || e["hash"] != hash {
+		t.Errorf("chained line %s is not JSON with its hash: %v", chained, err)
+	}
+
+	tests := []string{
+		"",
+		`{}`,
+		string(body),
+		`{"action":"run","hash":"` + strings.Repeat("z", 64) + `"}`,
+		strings.TrimSuffix(string(chained), `"}`) + `"`,
+	}
+	for _, line := range tests {
+		if _, _, ok := splitHash([]byte(line)); ok {
+			t.Errorf("splitHash(%s) reports a chained line", line)
+		}
+	}
+}
+
+// writeLog records plain unchained events, then chained ones, to a new audit log and returns its path and lines.
+func writeLog(t *testing.T, plain, chained int) (string, []string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < plain+chained; i++ {
+		cfg := Config{Path: path, HashChain: i >= plain}
+		if err := appendEvent(cfg, Event{Action: ActionRun, Cert: fmt.Sprintf("cert%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name            string
+		plain, chained  int
+		edit            func([]string) []string
+		events, chains  int
+		problems        []int // Lines with a problem
+		problemContains string
+	}{
+		{
+			name:    "intact chain",
+			chained: 4,
+			events:  4, chains: 4,
+		},
+		{
+			name:    "modified line",
+			chained: 3,
+			edit: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], "cert1", "certX", 1)
+				return lines
+			},
+			events: 3, chains: 3,
+			problems: []int{2}, problemContains: "hash mismatch",
+		},
+		{
+			name:    "removed middle line",
+			chained: 3,
+			edit:    func(lines []string) []string { return append(lines[:1], lines[2:]...) },
+			events:  2, chains: 2,
+			problems: []int{2}, problemContains: "prev_hash",
+		},
+		{
+			name:    "removed first line",
+			chained: 3,
+			edit:    func(lines []string) []string { return lines[1:] },
+			events:  2, chains: 2,
+			problems: []int{1}, problemContains: "earlier events were removed",
+		},
+		{
+			name:    "stripped hash",
+			chained: 3,
+			edit: func(lines []string) []string {
+				body, _, _ := splitHash([]byte(lines[1]))
+				lines[1] = string(body)
+				return lines
+			},
+			events: 3, chains: 2,
+			problems: []int{2, 3}, problemContains: "isn't chained",
+		},
+		{
+			name:  "unchained lines followed by chained ones",
+			plain: 2, chained: 2,
+			events: 4, chains: 2,
+		},
+		{
+			name:    "line that isn't JSON",
+			chained: 2,
+			edit:    func(lines []string) []string { return append(lines, "garbage") },
+			events:  3, chains: 2,
+			problems: []int{3}, problemContains: "not a JSON event",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, lines := writeLog(t, tt.plain, tt.chained)
+			if tt.edit != nil {
+				lines = tt.edit(lines)
+				if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			report, err := Verify(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Events != tt.events || report.Chained != tt.chains {
+				t.Errorf("events = %d, chained = %d, want %d, %d", report.Events, report.Chained, tt.events, tt.chains)
+			}
+			var got []int
+			for _, p := range report.Problems {
+				got = append(got, p.Line)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.problems) {
+				t.Errorf("problems on lines %v, want %v: %+v", got, tt.problems, report.Problems)
+			}
+			if len(report.Problems) > 0 && !strings.Contains(report.Problems[0].Error, tt.problemContains) {
+				t.Errorf("problem %q doesn't mention %q", report.Problems[0].Error, tt.problemContains)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingFile(t *testing.T) {
+	if _, err := Verify(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("Verify of a missing file succeeded")
+	}
+}
+
+func TestRecordConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	Configure(Config{Path: path, HashChain: true}, "test")
+	defer Configure(Config{}, "")
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Record(Event{Action: ActionHook, Cert: fmt.Sprintf("cert%d", i)}, nil)
+		}()
+	}
+	wg.Wait()
+
+	report, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != n || report.Chained != n || len(report.Problems) > 0 {
+		t.Errorf("Verify after %d concurrent records = %+v", n, report)
+	}
+}
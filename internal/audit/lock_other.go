@@ -0,0 +1,10 @@
+//go:build !unix
+
+package audit
+
+import "os"
+
+// lockFile does nothing: without flock, concurrent writers may interleave their events.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package certbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/tracing"
+)
+
+// auditIssuance records the certificates issued by the runs of records in the audit log, with the serial and
+// fingerprint of the new certificate.
+func auditIssuance(globals config.Globals, records []history.Record) {
+	for _, record := range records {
+		if !record.Issued() {
+			continue
+		}
+		event := audit.Event{
+			Action:  audit.ActionIssue,
+			Trigger: record.Trigger,
+			RunID:   record.RunID,
+			Cert:    record.Cert,
+			Domains: record.Domains,
+			Serial:  record.SerialAfter,
+			Details: map[string]any{"command": record.Command},
+		}
+		if record.SerialBefore != "" {
+			event.Details["replaced_serial"] = record.SerialBefore
+		}
+		if lineage, err := letsencrypt.FindLineage(globals.ConfigDir, record.Cert); err == nil && lineage != nil {
+			event.Fingerprint = lineage.Fingerprint()
+		}
+		audit.Record(event, nil)
+	}
+}
+
+// lineageActions maps the certbot subcommands run on existing lineages to their audit action.
+var lineageActions = map[string]string{
+	"revoke": audit.ActionRevoke,
+	"delete": audit.ActionDelete,
+}
+
+// auditLineageCommand records a revoke or delete of a lineage in the audit log. lineage is the lineage as it was
+// before the command, nil if it couldn't be read. err is the command's outcome.
+func auditLineageCommand(log *logrus.Entry, trigger string, args []string, name string, lineage *letsencrypt.Lineage, err error) {
+	action, ok := lineageActions[args[0]]
+	if !ok {
+		return
+	}
+	event := audit.Event{Action: action, Trigger: trigger, Cert: name, Details: map[string]any{}}
+	event.RunID, _ = log.Data[logging.FieldRunID].(string)
+	if lineage != nil {
+		event.Domains, event.Serial, event.Fingerprint = lineage.Domains(), lineage.Serial(), lineage.Fingerprint()
+	}
+	for i, arg := range args {
+		switch arg {
+		case "--reason":
+			if i+1 < len(args) {
+				event.Details["reason"] = args[i+1]
+			}
+		case "--delete-after-revoke":
+			event.Details["delete_after_revoke"] = true
+		}
+	}
+	audit.Record(event, err)
+}
+
+// Lines certbot prints about the hooks it runs: "Running deploy-hook command: <command>" before running one, and
+// "Hook 'deploy-hook' reported error code 1" when one fails. --quiet would suppress the former, so no command the
+// manager runs passes it.
+var (
+	hookRunPattern    = regexp.MustCompile(`(?m)Running ((?:pre|post|deploy|renew)-hook) command: (.+)$`)
+	hookFailedPattern = regexp.MustCompile(`(?m)Hook '(?:--)?((?:pre|post|deploy|renew)-hook)' reported error code (\d+)`)
+)
+
+// hookRun is a hook certbot reported running. exitCode is 0 unless certbot reported it failing.
+type hookRun struct {
+	hook, command string
+	exitCode      int
+}
+
+// parseHooks returns the hooks that certbot reported running in output, its stderr and stdout.
+func parseHooks(output string) []*hookRun {
+	var runs []*hookRun
+	for _, match := range hookRunPattern.FindAllStringSubmatch(output, -1) {
+		runs = append(runs, &hookRun{hook: match[1], command: match[2]})
+	}
+	for _, match := range hookFailedPattern.FindAllStringSubmatch(output, -1) {
+		exitCode, _ := strconv.Atoi(match[2])
+		var failed *hookRun
+		for _, run := range runs {
+			if run.hook == match[1] && run.exitCode == 0 {
+				failed = run
+			}
+		}
+		if failed == nil {
+			failed = &hookRun{hook: match[1]}
+			runs = append(runs, failed)
+		}
+		failed.exitCode = exitCode
+	}
+	return runs
+}
+
+// auditHooks records the hooks of a certbot run in the audit log.
+func auditHooks(log *logrus.Entry, subcommand string, runs []*hookRun) {
+	for _, run := range runs {
+		event := audit.Event{
+			Action:  audit.ActionHook,
+			Details: map[string]any{"hook": run.hook, "certbot_command": subcommand},
+		}
+		event.Trigger, _ = log.Data[logging.FieldTrigger].(string)
+		event.RunID, _ = log.Data[logging.FieldRunID].(string)
+		event.Cert, _ = log.Data[logging.FieldCert].(string)
+		if run.command != "" {
+			event.Details["command"] = run.command
+		}
+		var err error
+		if run.exitCode != 0 {
+			event.Details["exit_code"] = run.exitCode
+			err = fmt.Errorf("%s exited with code %d", run.hook, run.exitCode)
+		}
+		audit.Record(event, err)
+	}
+}
+
+// traceHooks adds a span per hook of a certbot run under ctx, the run's "certbot exec" span. certbot doesn't
+// report when hooks start or finish, so the spans only mark that they ran, and whether they failed.
+func traceHooks(ctx context.Context, runs []*hookRun) {
+	for _, run := range runs {
+		_, span := tracing.Start(ctx, "hook "+run.hook, tracing.AttrHook.String(run.hook))
+		if run.command != "" {
+			span.SetAttributes(tracing.AttrHookCommand.String(run.command))
+		}
+		var err error
+		if run.exitCode != 0 {
+			span.SetAttributes(tracing.AttrExitCode.Int(run.exitCode))
+			err = fmt.Errorf("%s exited with code %d", run.hook, run.exitCode)
+		}
+		tracing.End(span, err, "")
+	}
+}
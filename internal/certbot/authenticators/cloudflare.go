@@ -2,15 +2,20 @@ package authenticators
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	"certbot-manager/internal/certbot/flags"
 	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
 )
 
 type CloudflareAuthenticator struct{}
 
-func init() { Register("dns-cloudflare", &CloudflareAuthenticator{}) }
+func init() {
+	Register("dns-cloudflare", &CloudflareAuthenticator{}, "cloudflare_credentials_path", "dns_propagation_seconds")
+}
 
 func (p *CloudflareAuthenticator) BuildArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
 	credentialsPath := flags.ResolveString(certCfg.CloudflareCredentialsPath, globalCfg.CloudflareCredentialsPath)
@@ -38,3 +43,40 @@ func (p *CloudflareAuthenticator) BuildArgs(certCfg config.Certificate, globalCf
 
 	return args, nil
 }
+
+// Preflight checks that the credentials file is readable, private and holds an API token or key,
+// and that a propagation delay is set.
+func (p *CloudflareAuthenticator) Preflight(env preflight.Env, certCfg config.Certificate) []preflight.Result {
+	const check = "cloudflare_credentials_path"
+	results := []preflight.Result{checkPropagationSeconds(env, certCfg)}
+
+	credentialsPath := flags.ResolveString(certCfg.CloudflareCredentialsPath, env.Config.Globals.CloudflareCredentialsPath)
+	if credentialsPath == "" {
+		return append(results, preflight.Fail(check, "set cloudflare_credentials_path to a Cloudflare credentials ini file", "not set"))
+	}
+
+	info, err := os.Stat(credentialsPath)
+	if err != nil {
+		return append(results, preflight.Fail(check, "create the credentials file or mount it into the container", "%v", err))
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		results = append(results, preflight.Warn(check, fmt.Sprintf("chmod 600 %s", credentialsPath),
+			"'%s' is accessible by other users (mode %s); certbot warns about unsafe permissions", credentialsPath, info.Mode().Perm()))
+	}
+
+	data, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return append(results, preflight.Fail(check, "make the credentials file readable by the user running certbot", "%v", err))
+	}
+	content := string(data)
+	switch {
+	case strings.Contains(content, "dns_cloudflare_api_token"):
+		results = append(results, preflight.Pass(check, "'%s' holds an API token", credentialsPath))
+	case strings.Contains(content, "dns_cloudflare_api_key") && strings.Contains(content, "dns_cloudflare_email"):
+		results = append(results, preflight.Pass(check, "'%s' holds a global API key", credentialsPath))
+	default:
+		results = append(results, preflight.Fail(check, "add 'dns_cloudflare_api_token = <token>' to the credentials file",
+			"'%s' holds neither dns_cloudflare_api_token nor dns_cloudflare_api_key and dns_cloudflare_email", credentialsPath))
+	}
+	return results
+}
@@ -3,14 +3,19 @@ package authenticators
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"certbot-manager/internal/certbot/flags" // Import flags for helpers
 	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
 )
 
 type DuckDNSAuthenticator struct{}
 
-func init() { Register("dns-duckdns", &DuckDNSAuthenticator{}) }
+func init() {
+	Register("dns-duckdns", &DuckDNSAuthenticator{}, "duckdns_token", "dns_propagation_seconds")
+	flags.RegisterSecretOption("--dns-duckdns-token")
+}
 
 func (p *DuckDNSAuthenticator) BuildArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
 	token := flags.ResolveString(certCfg.DuckDNSToken, globalCfg.DuckDNSToken)
@@ -36,3 +41,22 @@ func (p *DuckDNSAuthenticator) BuildArgs(certCfg config.Certificate, globalCfg c
 
 	return args, nil
 }
+
+// Preflight checks that a token and a propagation delay are set, and that every domain is a DuckDNS subdomain.
+func (p *DuckDNSAuthenticator) Preflight(env preflight.Env, certCfg config.Certificate) []preflight.Result {
+	results := []preflight.Result{checkPropagationSeconds(env, certCfg)}
+
+	if flags.ResolveString(certCfg.DuckDNSToken, env.Config.Globals.DuckDNSToken) == "" {
+		results = append(results, preflight.Fail("duckdns_token", "set duckdns_token, e.g. duckdns_token = \"${DUCKDNS_TOKEN}\"", "not set"))
+	} else {
+		results = append(results, preflight.Pass("duckdns_token", "set"))
+	}
+
+	for _, domain := range certCfg.Domains {
+		if !strings.HasSuffix(strings.ToLower(domain), ".duckdns.org") {
+			results = append(results, preflight.Fail("domains", "use a different authenticator for domains outside duckdns.org",
+				"%s is not a DuckDNS subdomain", domain))
+		}
+	}
+	return results
+}
@@ -2,6 +2,7 @@ package authenticators
 
 import (
 	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
 )
 
 // Authenticator defines the interface for different certbot challenge methods.
@@ -10,3 +11,10 @@ type Authenticator interface {
 	// It receives the specific certificate config for context.
 	BuildArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error)
 }
+
+// Preflight is implemented by authenticators that can check their environment before certbot runs,
+// for `certbot-manager doctor`.
+type Preflight interface {
+	// Preflight checks what the authenticator needs for the certificate, e.g. that a credentials file is readable.
+	Preflight(env preflight.Env, certCfg config.Certificate) []preflight.Result
+}
@@ -0,0 +1,54 @@
+package authenticators
+
+import (
+	"fmt"
+
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
+)
+
+func init() { preflight.RegisterCertificate(checkAuthenticator) }
+
+// checkAuthenticator checks that the certificate's authenticator is known and installed in certbot, then runs the
+// authenticator's own checks.
+func checkAuthenticator(env preflight.Env, certCfg config.Certificate) []preflight.Result {
+	const check = "authenticator"
+	name, err := flags.ResolveAuthenticatorName(certCfg, env.Config.Globals)
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, "set 'authenticator' on the certificate or in [globals]", "%v", err)}
+	}
+	plugin, err := Get(name)
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, fmt.Sprintf("use one of %v", Names()), "%v", err)}
+	}
+
+	var results []preflight.Result
+	if env.HasPlugin(name) {
+		results = append(results, preflight.Pass(check, "certbot plugin '%s' is installed", name))
+	} else {
+		results = append(results, preflight.Fail(check,
+			fmt.Sprintf("install the certbot plugin providing '%s' (e.g. pip install certbot-%s)", name, name),
+			"certbot plugin '%s' is not installed (installed: %v)", name, env.Plugins))
+	}
+
+	if p, ok := plugin.(Preflight); ok {
+		results = append(results, p.Preflight(env, certCfg)...)
+	}
+	return results
+}
+
+// checkPropagationSeconds checks the dns_propagation_seconds setting the DNS authenticators require.
+func checkPropagationSeconds(env preflight.Env, certCfg config.Certificate) preflight.Result {
+	const check = "dns_propagation_seconds"
+	seconds := flags.ResolveIntPtr(certCfg.DNSPropagationSeconds, env.Config.Globals.DNSPropagationSeconds)
+	switch {
+	case seconds == nil:
+		return preflight.Fail(check, "set dns_propagation_seconds, e.g. 60", "not set")
+	case *seconds < 10:
+		return preflight.Warn(check, "DNS changes often take longer to propagate; consider 30 seconds or more",
+			"%d seconds may be too short", *seconds)
+	default:
+		return preflight.Pass(check, "%d seconds", *seconds)
+	}
+}
@@ -3,21 +3,26 @@ package authenticators
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 )
 
 // registry holds the registered authenticator plugins.
 var registry = make(map[string]Authenticator)
 
-// Register adds an authenticator plugin to the registry.
+// settings holds, for each registered plugin, the config keys it reads besides `authenticator`.
+var settings = make(map[string][]string)
+
+// Register adds an authenticator plugin to the registry, along with the config keys it reads.
 // It should be called from the init() function of each plugin implementation.
-func Register(name string, plugin Authenticator) {
+func Register(name string, plugin Authenticator, keys ...string) {
 	normalizedName := strings.ToLower(name)
 	if _, exists := registry[normalizedName]; exists {
 		log.Printf("Warning: Authenticator plugin '%s' is already registered. Overwriting.", normalizedName)
 	}
 	//log.Printf("Registering authenticator plugin: %s", normalizedName)
 	registry[normalizedName] = plugin
+	settings[normalizedName] = keys
 }
 
 // Get retrieves an authenticator plugin from the registry by name.
@@ -35,3 +40,18 @@ func Get(name string) (Authenticator, error) {
 	}
 	return plugin, nil
 }
+
+// Names returns the names of all registered authenticator plugins, sorted alphabetically.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings returns the config keys a registered plugin reads besides `authenticator`.
+func Settings(name string) []string {
+	return settings[strings.ToLower(name)]
+}
@@ -3,12 +3,21 @@ package authenticators
 import (
 	"certbot-manager/internal/certbot/flags"
 	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type WebrootAuthenticator struct{}
 
-func init() { Register("webroot", &WebrootAuthenticator{}) }
+func init() { Register("webroot", &WebrootAuthenticator{}, "webroot_path") }
 
 func (p *WebrootAuthenticator) BuildArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
 	webrootPath := flags.ResolveString(certCfg.WebrootPath, globalCfg.WebrootPath)
@@ -18,3 +27,74 @@ func (p *WebrootAuthenticator) BuildArgs(certCfg config.Certificate, globalCfg c
 
 	return []string{"--webroot", "-w", webrootPath}, nil
 }
+
+// Preflight checks that the webroot is writable and, unless offline, that every domain serves files from its
+// ACME challenge directory over HTTP.
+func (p *WebrootAuthenticator) Preflight(env preflight.Env, certCfg config.Certificate) []preflight.Result {
+	webrootPath := flags.ResolveString(certCfg.WebrootPath, env.Config.Globals.WebrootPath)
+	if webrootPath == "" {
+		return []preflight.Result{preflight.Fail("webroot_path", "set webroot_path on the certificate or in [globals]", "not set")}
+	}
+
+	results := []preflight.Result{preflight.CheckDirectory("webroot_path", webrootPath)}
+	if results[0].Status == preflight.StatusFail || env.Offline {
+		return results
+	}
+	return append(results, checkChallengeServed(webrootPath, certCfg.Domains)...)
+}
+
+// checkChallengeServed writes a token to the webroot's ACME challenge directory and fetches it from every domain,
+// like the ACME server does during HTTP-01 validation.
+func checkChallengeServed(webrootPath string, domains []string) []preflight.Result {
+	const check = "http-01"
+	challengeDir := filepath.Join(webrootPath, ".well-known", "acme-challenge")
+
+	var created []string
+	for dir := challengeDir; dir != webrootPath; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			created = append(created, dir)
+		}
+	}
+	if err := os.MkdirAll(challengeDir, 0o755); err != nil {
+		return []preflight.Result{preflight.Fail(check, "make the webroot writable", "can't create '%s': %v", challengeDir, err)}
+	}
+	defer func() {
+		for _, dir := range created {
+			_ = os.Remove(dir)
+		}
+	}()
+
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	name := "certbot-manager-doctor-" + hex.EncodeToString(token)
+	tokenPath := filepath.Join(challengeDir, name)
+	if err := os.WriteFile(tokenPath, []byte(name), 0o644); err != nil {
+		return []preflight.Result{preflight.Fail(check, "make the webroot writable", "can't write '%s': %v", tokenPath, err)}
+	}
+	defer os.Remove(tokenPath)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	var results []preflight.Result
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") {
+			results = append(results, preflight.Fail(check, "use a DNS authenticator for wildcard domains",
+				"%s: wildcard certificates can't be validated with webroot", domain))
+			continue
+		}
+		url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, name)
+		hint := fmt.Sprintf("serve '%s' at http://%s/.well-known/acme-challenge/ (e.g. an nginx location block)", challengeDir, domain)
+		resp, err := client.Get(url)
+		if err != nil {
+			results = append(results, preflight.Warn(check, hint, "%s: %v", domain, err))
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != name {
+			results = append(results, preflight.Warn(check, hint, "%s: the challenge file isn't served (HTTP %d)", domain, resp.StatusCode))
+			continue
+		}
+		results = append(results, preflight.Pass(check, "%s serves the webroot's challenge files", domain))
+	}
+	return results
+}
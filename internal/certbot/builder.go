@@ -6,6 +6,7 @@ import (
 	"certbot-manager/internal/config"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // ArgsBuilder now primarily holds the context (configs) needed during the build process.
@@ -15,6 +16,13 @@ type ArgsBuilder struct {
 	globalCfg config.Globals
 }
 
+// ArgGroup holds the arguments contributed by one step of the build, and the config keys that step reads.
+type ArgGroup struct {
+	Step     string // "cmd", a flag generator name, "authenticator:<name>", "args" or "domains"
+	Args     []string
+	Settings []string
+}
+
 // NewArgsBuilder simply stores the configuration context.
 func NewArgsBuilder(cfg config.Certificate, globals config.Globals) *ArgsBuilder {
 	return &ArgsBuilder{
@@ -26,6 +34,21 @@ func NewArgsBuilder(cfg config.Certificate, globals config.Globals) *ArgsBuilder
 // Build constructs the final argument list using flag generators and the authenticator plugin,
 // passing the config context to them.
 func (b *ArgsBuilder) Build() ([]string, error) {
+	groups, err := b.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for _, group := range groups {
+		args = append(args, group.Args...)
+	}
+	return args, nil
+}
+
+// Plan constructs the same arguments as Build, grouped by the step that produced them.
+// Groups of flag generators that produced no arguments are kept, so every registered generator is reported.
+func (b *ArgsBuilder) Plan() ([]ArgGroup, error) {
 	// Minimal validation here, more specific validation happens in generators/plugins
 	if len(b.certCfg.Domains) == 0 {
 		return nil, errors.New("at least one domain is required")
@@ -37,6 +60,7 @@ func (b *ArgsBuilder) Build() ([]string, error) {
 		return nil, fmt.Errorf("error from cmd generator %s for domains %v: %w", b.certCfg.Cmd, b.certCfg.Domains, err)
 	}
 
+	groups := []ArgGroup{{Step: "cmd", Args: []string{cmd}, Settings: []string{"cmd"}}}
 	args := []string{cmd}
 
 	// Apply Common Flags via Registered Generators
@@ -47,6 +71,8 @@ func (b *ArgsBuilder) Build() ([]string, error) {
 			typeName := fmt.Sprintf("%T", generator)
 			return nil, fmt.Errorf("error from flag generator %s for domains %v: %w", typeName, b.certCfg.Domains, err)
 		}
+		stepName := strings.TrimPrefix(fmt.Sprintf("%T", generator), "*flags.")
+		groups = append(groups, ArgGroup{Step: stepName, Args: flagArgs, Settings: flags.Settings(generator)})
 		if len(flagArgs) > 0 {
 			args = append(args, flagArgs...)
 		}
@@ -67,13 +93,30 @@ func (b *ArgsBuilder) Build() ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to build args for authenticator '%s' (domains: %v): %w", authenticatorName, b.certCfg.Domains, err)
 	}
+	groups = append(groups, ArgGroup{
+		Step:     "authenticator:" + authenticatorName,
+		Args:     authArgs,
+		Settings: append([]string{"authenticator"}, authenticators.Settings(authenticatorName)...),
+	})
 	if len(authArgs) > 0 {
 		args = append(args, authArgs...)
 	}
 
+	// Apply Custom Args (global and per-certificate), rejecting those that override managed flags
+	customArgs, err := flags.ResolveArgs(b.certCfg, b.globalCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid args (domains: %v): %w", b.certCfg.Domains, err)
+	}
+	if err := flags.CheckArgCollisions(customArgs, args); err != nil {
+		return nil, fmt.Errorf("invalid args (domains: %v): %w", b.certCfg.Domains, err)
+	}
+	groups = append(groups, ArgGroup{Step: "args", Args: customArgs, Settings: []string{"args", "args_mode"}})
+
+	var domainArgs []string
 	for _, domain := range b.certCfg.Domains {
-		args = append(args, "-d", domain)
+		domainArgs = append(domainArgs, "-d", domain)
 	}
+	groups = append(groups, ArgGroup{Step: "domains", Args: domainArgs, Settings: []string{"domains"}})
 
-	return args, nil
+	return groups, nil
 }
@@ -23,6 +23,11 @@ func generateCmd(certCfg config.Certificate, globalCfg config.Globals) (string,
 	return cmd, nil
 }
 
+// Commands returns the certbot subcommands accepted by the `cmd` setting.
+func Commands() []string {
+	return append([]string(nil), commandList...)
+}
+
 func isValidCommand(cmd string) bool {
 	for _, validCmd := range commandList {
 		if cmd == validCmd {
@@ -0,0 +1,71 @@
+package certbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CommandError is returned when a certbot command fails to start or exits with a non-zero status.
+type CommandError struct {
+	ExitCode int    // -1 if the command couldn't be started
+	Stderr   string // Trimmed standard error output
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command execution failed (exit code %d): %v", e.ExitCode, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Error classes reported by ErrorClass, used as metric labels and in the run history.
+const (
+	ErrorClassNone            = "success"
+	ErrorClassConfig          = "config"           // The certbot arguments couldn't be built
+	ErrorClassExec            = "exec"             // certbot couldn't be started
+	ErrorClassRateLimited     = "rate_limited"     // The ACME server refused the order because of a rate limit
+	ErrorClassDNS             = "dns"              // DNS lookups failed or DNS-01 records weren't found
+	ErrorClassChallengeFailed = "challenge_failed" // The ACME server couldn't validate a challenge
+	ErrorClassNetwork         = "network"          // The ACME server couldn't be reached
+	ErrorClassLocked          = "locked"           // Another certbot instance was running
+	ErrorClassCertbot         = "certbot"          // Any other certbot failure
+)
+
+// errorPatterns maps certbot error messages to their class, checked in order.
+var errorPatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{ErrorClassRateLimited, []string{"too many certificates", "too many failed authorizations", "too many new orders", "ratelimited", "rate limit"}},
+	{ErrorClassDNS, []string{"dns problem", "nxdomain", "servfail", "incorrect txt record", "no txt record"}},
+	{ErrorClassChallengeFailed, []string{"some challenges have failed", "challenge failed", ":unauthorized", "invalid response from"}},
+	{ErrorClassNetwork, []string{"connection refused", "max retries exceeded", "failed to establish a new connection", "timed out", "name or service not known"}},
+	{ErrorClassLocked, []string{"another instance of certbot is already running"}},
+}
+
+// ErrorClass classifies the error of a certbot run by looking at certbot's error output.
+// Errors that don't come from running certbot are argument building errors and classed as ErrorClassConfig.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ErrorClassNone
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return ErrorClassConfig
+	}
+	if cmdErr.ExitCode < 0 {
+		return ErrorClassExec
+	}
+	stderr := strings.ToLower(cmdErr.Stderr)
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(stderr, pattern) {
+				return p.class
+			}
+		}
+	}
+	return ErrorClassCertbot
+}
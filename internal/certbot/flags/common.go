@@ -2,6 +2,8 @@ package flags
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"certbot-manager/internal/config"
 )
@@ -10,7 +12,7 @@ import (
 
 type EmailFlag struct{}
 
-func init() { Register(&EmailFlag{}) }
+func init() { Register(&EmailFlag{}, "email") }
 
 // GenerateArgs now takes config structs
 func (f *EmailFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
@@ -47,9 +49,16 @@ func (f *NonInteractiveFlag) GenerateArgs(_ config.Certificate, _ config.Globals
 
 type StagingFlag struct{}
 
-func init() { Register(&StagingFlag{}) }
+func init() { Register(&StagingFlag{}, "server", "staging") }
 
+// GenerateArgs selects the ACME server: --server for a custom one, which takes precedence, else --staging.
 func (f *StagingFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
+	if server := ResolveString(certCfg.Server, globalCfg.Server); server != "" {
+		if !strings.HasPrefix(server, "https://") && !strings.HasPrefix(server, "http://") {
+			return nil, fmt.Errorf("server '%s' must be the URL of an ACME directory", server)
+		}
+		return []string{"--server", server}, nil
+	}
 	isStaging := ResolveBoolPtr(certCfg.Staging, globalCfg.Staging)
 	if isStaging != nil && *isStaging {
 		return []string{"--staging"}, nil
@@ -61,7 +70,7 @@ func (f *StagingFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.
 
 type NoEffEmailFlag struct{}
 
-func init() { Register(&NoEffEmailFlag{}) }
+func init() { Register(&NoEffEmailFlag{}, "no_eff_email") }
 
 func (f *NoEffEmailFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
 	noEffEmail := ResolveBoolPtr(certCfg.NoEffEmail, globalCfg.NoEffEmail)
@@ -73,14 +82,41 @@ func (f *NoEffEmailFlag) GenerateArgs(certCfg config.Certificate, globalCfg conf
 
 // --- Key Type Flag ---
 
+// KeyTypes lists the values accepted by certbot's --key-type.
+var KeyTypes = []string{"rsa", "ecdsa"}
+
 type KeyTypeFlag struct{}
 
-func init() { Register(&KeyTypeFlag{}) }
+func init() { Register(&KeyTypeFlag{}, "key_type") }
 
 func (f *KeyTypeFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
 	keyType := ResolveString(certCfg.KeyType, globalCfg.KeyType)
-	if keyType != "" {
-		return []string{"--key-type", keyType}, nil
+	if keyType == "" {
+		return nil, nil
 	}
-	return nil, nil
+	for _, valid := range KeyTypes {
+		if keyType == valid {
+			return []string{"--key-type", keyType}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key_type '%s' (options: %v)", keyType, KeyTypes)
+}
+
+// --- Config Dir Flag ---
+
+type ConfigDirFlag struct{}
+
+func init() { Register(&ConfigDirFlag{}, "config_dir") }
+
+func (f *ConfigDirFlag) GenerateArgs(_ config.Certificate, globalCfg config.Globals) ([]string, error) {
+	return ConfigDirArgs(globalCfg), nil
+}
+
+// ConfigDirArgs returns --config-dir when the configured certbot directory isn't certbot's default.
+// Every certbot invocation (certonly, renew, delete, revoke) must use it to operate on the same lineages.
+func ConfigDirArgs(globalCfg config.Globals) []string {
+	if globalCfg.ConfigDir == "" || globalCfg.ConfigDir == config.Defaults.ConfigDir {
+		return nil
+	}
+	return []string{"--config-dir", globalCfg.ConfigDir}
 }
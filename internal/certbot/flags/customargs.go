@@ -1,14 +1,94 @@
 package flags
 
-import "certbot-manager/internal/config"
+import (
+	"fmt"
+	"strings"
 
-type CustomArgsFlag struct{}
+	"certbot-manager/internal/config"
+)
 
-func init() { Register(&CustomArgsFlag{}) }
+// managedFlags maps certbot options the manager sets itself, including aliases and options that are only
+// emitted conditionally, to the setting that controls them. Custom args must not repeat them.
+var managedFlags = map[string]string{
+	"-m":                    "email",
+	"--email":               "email",
+	"--agree-tos":           "(always set)",
+	"-n":                    "(always set)",
+	"--non-interactive":     "(always set)",
+	"--noninteractive":      "(always set)",
+	"--staging":             "staging",
+	"--test-cert":           "staging",
+	"--server":              "server",
+	"--no-eff-email":        "no_eff_email",
+	"--eff-email":           "no_eff_email",
+	"--key-type":            "key_type",
+	"--force-renewal":       "initial_force_renewal",
+	"--renew-by-default":    "initial_force_renewal",
+	"--keep-until-expiring": "initial_force_renewal",
+	"--keep":                "initial_force_renewal",
+	"--reinstall":           "initial_force_renewal",
+	"-a":                    "authenticator",
+	"--authenticator":       "authenticator",
+	"-w":                    "webroot_path",
+	"--webroot":             "authenticator",
+	"--webroot-path":        "webroot_path",
+	"-d":                    "domains",
+	"--domain":              "domains",
+	"--domains":             "domains",
+	"--config-dir":          "config_dir",
+}
+
+// ResolveArgs returns the custom args for a certificate: the global args followed by the certificate args,
+// or only the certificate args when args_mode is "replace".
+func ResolveArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
+	mode := ResolveString(certCfg.ArgsMode, globalCfg.ArgsMode)
+	switch mode {
+	case "", config.ArgsModeAppend:
+		args := append([]string{}, globalCfg.Args...)
+		return append(args, certCfg.Args...), nil
+	case config.ArgsModeReplace:
+		return append([]string{}, certCfg.Args...), nil
+	default:
+		return nil, fmt.Errorf("unknown args_mode '%s' (options: %v)", mode, config.ArgsModes)
+	}
+}
+
+// CheckArgCollisions reports custom args that repeat an option the manager already controls, either one listed
+// in managedFlags or one present in managedArgs (the arguments generated from the configuration).
+func CheckArgCollisions(customArgs []string, managedArgs []string) error {
+	generated := make(map[string]bool)
+	for _, arg := range managedArgs {
+		if name := optionName(arg); name != "" {
+			generated[name] = true
+		}
+	}
 
-func (f *CustomArgsFlag) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
-	if certCfg.Args != "" {
-		return []string{certCfg.Args}, nil
+	var collisions []string
+	for _, arg := range customArgs {
+		name := optionName(arg)
+		if name == "" {
+			continue
+		}
+		if setting, ok := managedFlags[name]; ok {
+			collisions = append(collisions, fmt.Sprintf("'%s' (use the '%s' setting)", name, setting))
+		} else if generated[name] {
+			collisions = append(collisions, fmt.Sprintf("'%s' (already set from the configuration)", name))
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("args collide with flags set by certbot-manager: %s", strings.Join(collisions, ", "))
+	}
+	return nil
+}
+
+// optionName returns the option name of a command line argument ("--email=x" -> "--email"),
+// or an empty string if the argument is not an option.
+func optionName(arg string) string {
+	if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+		return ""
+	}
+	if idx := strings.IndexByte(arg, '='); idx >= 0 {
+		return arg[:idx]
 	}
-	return nil, nil
+	return arg
 }
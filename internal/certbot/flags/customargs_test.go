@@ -0,0 +1,49 @@
+package flags
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckArgCollisions(t *testing.T) {
+	managed := []string{"certonly", "--cert-name", "a.org", "--rsa-key-size=4096", "-d", "a.org"}
+	tests := []struct {
+		args []string
+		want []string // Substrings of the expected error, none if the args are accepted
+	}{
+		{args: nil},
+		{args: []string{"--preferred-chain", "ISRG Root X1"}},
+		{args: []string{"--preferred-chain=ISRG Root X1", "--must-staple"}},
+		{args: []string{"-", "--", "value=--email"}},
+		{args: []string{"--email", "x@y.z"}, want: []string{"'--email' (use the 'email' setting)"}},
+		{args: []string{"--email=x@y.z"}, want: []string{"'--email' (use the 'email' setting)"}},
+		{args: []string{"-m", "x@y.z"}, want: []string{"'-m' (use the 'email' setting)"}},
+		{args: []string{"--server=https://ca.example"}, want: []string{"'--server' (use the 'server' setting)"}},
+		{args: []string{"--key-type=rsa"}, want: []string{"'--key-type' (use the 'key_type' setting)"}},
+		{args: []string{"--domains=b.org"}, want: []string{"'--domains' (use the 'domains' setting)"}},
+		{args: []string{"--cert-name=b.org"}, want: []string{"'--cert-name' (already set from the configuration)"}},
+		{args: []string{"--rsa-key-size", "2048"}, want: []string{"'--rsa-key-size' (already set from the configuration)"}},
+		{
+			args: []string{"--staging", "--must-staple", "--config-dir=/tmp"},
+			want: []string{"'--staging' (use the 'staging' setting)", "'--config-dir' (use the 'config_dir' setting)"},
+		},
+	}
+	for _, tt := range tests {
+		err := CheckArgCollisions(tt.args, managed)
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("CheckArgCollisions(%q) = %v, want no error", tt.args, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("CheckArgCollisions(%q) accepted the args", tt.args)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("CheckArgCollisions(%q) = %v, want it to mention %s", tt.args, err, want)
+			}
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 // InitialRunFlags handles --force-renewal or --keep-until-expiring.
 type InitialRunFlags struct{}
 
-func init() { Register(&InitialRunFlags{}) } // Register this generator
+func init() { Register(&InitialRunFlags{}, "initial_force_renewal") } // Register this generator
 
 // GenerateArgs now takes config structs
 func (f *InitialRunFlags) GenerateArgs(certCfg config.Certificate, globalCfg config.Globals) ([]string, error) {
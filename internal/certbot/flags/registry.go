@@ -3,12 +3,17 @@ package flags
 // registry holds the registered flag generator instances. Order might matter for CLI readability.
 var registry = []FlagGenerator{}
 
-// Register adds a flag generator instance to the registry.
+// settings holds, for each registered generator, the config keys it reads.
+var settings = map[FlagGenerator][]string{}
+
+// Register adds a flag generator instance to the registry, along with the config keys it reads
+// (used to report where each generated flag came from).
 // Called from init() functions in implementation files.
-func Register(generator FlagGenerator) {
+func Register(generator FlagGenerator, keys ...string) {
 	//typeName := reflect.TypeOf(generator).Elem().Name() // Get struct name
 	//log.Printf("Registering flag generator: %s", typeName)
 	registry = append(registry, generator)
+	settings[generator] = keys
 }
 
 // GetAll retrieves all registered flag generators.
@@ -16,3 +21,8 @@ func GetAll() []FlagGenerator {
 	// Return a copy to prevent external modification? For now, return direct slice.
 	return registry
 }
+
+// Settings returns the config keys a registered generator reads.
+func Settings(generator FlagGenerator) []string {
+	return settings[generator]
+}
@@ -0,0 +1,32 @@
+package flags
+
+import (
+	"strings"
+
+	"certbot-manager/internal/config"
+)
+
+// secretOptions holds the certbot options whose value is a secret.
+var secretOptions = map[string]bool{}
+
+// RegisterSecretOption marks a certbot option as taking a secret value, so MaskArgs hides it.
+// Called from init() functions of the authenticators that pass secrets on the command line.
+func RegisterSecretOption(name string) {
+	secretOptions[name] = true
+}
+
+// MaskArgs returns a copy of args with the values of secret options replaced by config.MaskedValue,
+// for both the "--option value" and "--option=value" forms.
+func MaskArgs(args []string) []string {
+	masked := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		masked[i] = args[i]
+		if name, _, hasValue := strings.Cut(args[i], "="); hasValue && secretOptions[name] {
+			masked[i] = name + "=" + config.MaskedValue
+		} else if secretOptions[args[i]] && i+1 < len(args) {
+			i++
+			masked[i] = config.MaskedValue
+		}
+	}
+	return masked
+}
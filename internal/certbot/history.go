@@ -0,0 +1,89 @@
+package certbot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+)
+
+// newRecord describes a finished certbot run for the run history. err is the run's outcome; a CommandError
+// provides the exit code and stderr.
+func newRecord(log *logrus.Entry, command, trigger string, start time.Time, err error) history.Record {
+	record := history.Record{
+		Command:    command,
+		Trigger:    trigger,
+		Start:      start.UTC(),
+		End:        time.Now().UTC(),
+		ErrorClass: ErrorClass(err),
+	}
+	record.RunID, _ = log.Data[logging.FieldRunID].(string)
+	if err == nil {
+		return record
+	}
+	record.Error = err.Error()
+	record.ExitCode = -1
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		record.ExitCode = cmdErr.ExitCode
+		record.StderrTail = history.Tail(cmdErr.Stderr)
+	}
+	return record
+}
+
+// recordHistory appends records to the run history in the state directory and drops those older than
+// history_retention_days. The certificates the runs issued are recorded in the audit log. Failures are logged: the
+// history must never fail a run.
+func recordHistory(log *logrus.Entry, globals config.Globals, records ...history.Record) {
+	auditIssuance(globals, records)
+	if err := history.Append(globals.StateDir, records...); err != nil {
+		log.Warnf("Failed to record run history: %v", err)
+		return
+	}
+	if globals.HistoryRetentionDays <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -globals.HistoryRetentionDays)
+	if removed, err := history.Prune(globals.StateDir, cutoff); err != nil {
+		log.Warnf("Failed to prune run history: %v", err)
+	} else if removed > 0 {
+		log.Debugf("Pruned %d run history record(s) older than %d days.", removed, globals.HistoryRetentionDays)
+	}
+}
+
+// lineageSerial returns the name and serial of the lineage issued for domains, empty if there is none.
+func lineageSerial(configDir string, domains []string) (name, serial string) {
+	lineages, err := letsencrypt.Lineages(configDir)
+	if err != nil {
+		return "", ""
+	}
+	if lineage := letsencrypt.MatchLineage(lineages, domains); lineage != nil {
+		return lineage.Name, lineage.Serial()
+	}
+	return "", ""
+}
+
+// recordRenewal records a `certbot renew` run for every lineage it covered: those whose renewal isn't stopped.
+// before holds the serials read before the run.
+func recordRenewal(log *logrus.Entry, globals config.Globals, trigger string, start time.Time, before map[string]string, err error) {
+	lineages, _ := letsencrypt.Lineages(globals.ConfigDir)
+	var records []history.Record
+	for _, lineage := range lineages {
+		if lineage.Disabled {
+			continue
+		}
+		record := newRecord(log, "renew", trigger, start, err)
+		record.Cert, record.Domains = lineage.Name, lineage.Domains()
+		record.SerialBefore, record.SerialAfter = before[lineage.Name], lineage.Serial()
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		records = append(records, newRecord(log, "renew", trigger, start, err))
+	}
+	recordHistory(log, globals, records...)
+}
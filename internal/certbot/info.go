@@ -0,0 +1,40 @@
+package certbot
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Version returns the version reported by `certbot --version`, e.g. "4.0.0".
+func Version(certbotPath string) (string, error) {
+	out, err := exec.Command(certbotPath, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run '%s --version': %w", certbotPath, err)
+	}
+	version := strings.TrimSpace(string(out))
+	// Older releases print "certbot 1.2.3", newer ones may add log lines before it.
+	if lines := strings.Split(version, "\n"); len(lines) > 1 {
+		version = strings.TrimSpace(lines[len(lines)-1])
+	}
+	return strings.TrimPrefix(version, "certbot "), nil
+}
+
+// Plugins returns the names of the installed certbot plugins, as listed by `certbot plugins`.
+func Plugins(certbotPath string) ([]string, error) {
+	out, err := exec.Command(certbotPath, "plugins").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run '%s plugins': %w", certbotPath, err)
+	}
+
+	var plugins []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "* "); ok {
+			plugins = append(plugins, strings.TrimSpace(name))
+		}
+	}
+	return plugins, scanner.Err()
+}
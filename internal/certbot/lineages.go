@@ -0,0 +1,321 @@
+package certbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/state"
+	"certbot-manager/internal/tracing"
+)
+
+// ReconcileLineages compares the lineages recorded in the manager state with the configured certificates.
+// Recorded lineages that no longer match any certificate are orphans and get the configured orphan_policy;
+// stopped lineages that are configured again get their renewal resumed.
+// It runs before the initial certificate requests, at startup and on reload, traced in a "reconcile" span under ctx.
+// The state is read once, and the changes are applied with state.Update so changes made meanwhile are kept.
+func ReconcileLineages(ctx context.Context, cfg *config.Config, certbotPath string, trigger string) (err error) {
+	ctx, span := tracing.Start(ctx, "reconcile", tracing.AttrTrigger.String(trigger))
+	defer func() { tracing.End(span, err, "") }()
+	runLog := tracing.WithSpan(ctx, logging.Component("reconciler"))
+	runLog.Info("--- Reconciling Managed Lineages ---")
+	st, err := state.Open(cfg.Globals.StateDir)
+	if err != nil {
+		return err
+	}
+	policy := cfg.Globals.OrphanPolicy
+	if len(cfg.Certificates) == 0 && len(st.Lineages) > 0 && destructivePolicy(policy) {
+		// A mistyped [[certificate]] table must not remove every managed lineage.
+		return fmt.Errorf("the configuration has no certificates; refusing to apply orphan_policy = %s to the %d managed lineage(s)",
+			policy, len(st.Lineages))
+	}
+
+	var errs []error
+	var changes []func(*state.State)
+	for _, name := range st.LineageNames() {
+		recorded := st.Lineages[name]
+		log := runLog.WithFields(logrus.Fields{logging.FieldCert: name, logging.FieldDomains: recorded.Domains})
+
+		lineage, err := letsencrypt.FindLineage(cfg.Globals.ConfigDir, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if lineage == nil {
+			log.Infof("Managed lineage '%s' no longer exists in %s. Forgetting it.", name, cfg.Globals.ConfigDir)
+			changes = append(changes, forgetLineage(name))
+			continue
+		}
+		if lineage.Err != nil {
+			errs = append(errs, fmt.Errorf("skipping lineage '%s': %w", name, lineage.Err))
+			continue
+		}
+
+		if isConfigured(cfg, recorded.Domains) {
+			if recorded.RenewalStopped {
+				log.Infof("Lineage '%s' (%v) is configured again. Resuming its renewal.", name, recorded.Domains)
+				if err := letsencrypt.ResumeRenewal(*lineage); err != nil {
+					errs = append(errs, fmt.Errorf("failed to resume renewal of lineage '%s': %w", name, err))
+					continue
+				}
+				changes = append(changes, setRenewalStopped(name, false))
+			}
+			continue
+		}
+
+		change, err := applyOrphanPolicy(ctx, log, policy, cfg.Globals, certbotPath, *lineage, recorded, trigger)
+		if change != nil {
+			changes = append(changes, change)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(changes) > 0 {
+		err := state.Update(cfg.Globals.StateDir, func(s *state.State) error {
+			for _, change := range changes {
+				change(s)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RecordLineages records in the manager state the lineages that the initial certificate requests issued, so they are
+// recognized as orphans once their certificate is removed from the configuration, and the outcome of the requests
+// on the lineages already recorded. before holds the serials read before the requests, see letsencrypt.Serials, and
+// results the errors returned by RequestCertificates. A lineage is only recorded if its certificate's request
+// succeeded and changed its serial: lineages issued by hand or by other tools are never recorded, see Adopt.
+func RecordLineages(cfg *config.Config, before map[string]string, results []error) error {
+	lineages, err := letsencrypt.Lineages(cfg.Globals.ConfigDir)
+	if err != nil {
+		return err
+	}
+	return state.Update(cfg.Globals.StateDir, func(st *state.State) error {
+		recordLineages(cfg, st, lineages, before, results)
+		return nil
+	})
+}
+
+// recordLineages is RecordLineages on the state being updated.
+func recordLineages(cfg *config.Config, st *state.State, lineages []letsencrypt.Lineage, before map[string]string, results []error) {
+	for i, cert := range cfg.Certificates {
+		for _, lineage := range lineages {
+			if lineage.Disabled || lineage.Err != nil || !letsencrypt.SameDomains(lineage.Domains(), cert.Domains) {
+				continue
+			}
+			if _, known := st.Lineages[lineage.Name]; !known {
+				issued := i < len(results) && results[i] == nil && lineage.Serial() != "" && lineage.Serial() != before[lineage.Name]
+				if !issued {
+					continue
+				}
+				logging.Component("reconciler").WithField(logging.FieldCert, lineage.Name).
+					Debugf("Recording managed lineage '%s' for domains %v", lineage.Name, cert.Domains)
+				st.Lineages[lineage.Name] = newRecordedLineage(lineage)
+			}
+			if i < len(results) {
+				st.Lineages[lineage.Name].LastRun = state.NewRun(flags.ResolveString(cert.Cmd, cfg.Globals.Cmd), results[i])
+			}
+		}
+	}
+}
+
+// Adopt records lineages in the manager state as managed, e.g. those issued by hand before migrating to
+// certbot-manager, so orphan_policy applies to them. Lineages already recorded in the state in stateDir are left
+// as they are. It returns the names of the lineages it recorded.
+func Adopt(stateDir string, lineages []letsencrypt.Lineage) ([]string, error) {
+	var adopted []string
+	err := state.Update(stateDir, func(st *state.State) error {
+		adopted = nil
+		for _, lineage := range lineages {
+			if _, known := st.Lineages[lineage.Name]; known || lineage.Disabled {
+				continue
+			}
+			st.Lineages[lineage.Name] = newRecordedLineage(lineage)
+			adopted = append(adopted, lineage.Name)
+		}
+		return nil
+	})
+	return adopted, err
+}
+
+func newRecordedLineage(lineage letsencrypt.Lineage) *state.Lineage {
+	return &state.Lineage{
+		Domains:    lineage.Domains(),
+		Server:     lineage.Renewal.Server(),
+		RecordedAt: time.Now().UTC(),
+	}
+}
+
+// RecordRenewal records the outcome of a `certbot renew` pass on every managed lineage it covered, in the state in
+// stateDir.
+func RecordRenewal(stateDir string, err error) error {
+	return state.Update(stateDir, func(st *state.State) error {
+		for _, lineage := range st.Lineages {
+			if !lineage.RenewalStopped {
+				lineage.LastRun = state.NewRun("renew", err)
+			}
+		}
+		return nil
+	})
+}
+
+// forgetLineage returns a state change removing the named lineage.
+func forgetLineage(name string) func(*state.State) {
+	return func(st *state.State) {
+		delete(st.Lineages, name)
+	}
+}
+
+// setRenewalStopped returns a state change marking whether the named lineage's renewal is stopped.
+func setRenewalStopped(name string, stopped bool) func(*state.State) {
+	return func(st *state.State) {
+		if lineage, ok := st.Lineages[name]; ok {
+			lineage.RenewalStopped = stopped
+		}
+	}
+}
+
+// DeleteLineage runs `certbot delete` for the named lineage. trigger is recorded in the run history.
+func DeleteLineage(ctx context.Context, certbotPath string, globals config.Globals, name string, trigger string) error {
+	args := []string{"delete", "--cert-name", name, "--non-interactive"}
+	args = append(args, flags.ConfigDirArgs(globals)...)
+	return runLineageCommand(ctx, certbotPath, globals, name, trigger, args)
+}
+
+// RevokeReasons lists the values certbot accepts for `revoke --reason`.
+var RevokeReasons = []string{"unspecified", "keycompromise", "affiliationchanged", "superseded", "cessationofoperation"}
+
+// RevokeLineage runs `certbot revoke` for the named lineage. serverArgs select the ACME server the lineage was
+// issued by, see ServerArgs. reason is one of RevokeReasons; deleteAfter also deletes the lineage once revoked.
+// trigger is recorded in the run history.
+func RevokeLineage(ctx context.Context, certbotPath string, globals config.Globals, name string, serverArgs []string, reason string, deleteAfter bool, trigger string) error {
+	args := []string{"revoke", "--cert-name", name, "--non-interactive", "--reason", reason}
+	args = append(args, serverArgs...)
+	if deleteAfter {
+		args = append(args, "--delete-after-revoke")
+	} else {
+		args = append(args, "--no-delete-after-revoke")
+	}
+	args = append(args, flags.ConfigDirArgs(globals)...)
+	return runLineageCommand(ctx, certbotPath, globals, name, trigger, args)
+}
+
+// runLineageCommand runs a certbot command on an existing lineage and records it in the run history and the audit
+// log. It is traced in a "certificate" span under ctx, like a certificate request.
+func runLineageCommand(ctx context.Context, certbotPath string, globals config.Globals, name, trigger string, args []string) (err error) {
+	log := newRunLogger(trigger).WithField(logging.FieldCert, name)
+	start := time.Now()
+	var domains []string
+	var serialBefore string
+	lineage, findErr := letsencrypt.FindLineage(globals.ConfigDir, name)
+	if findErr == nil && lineage != nil {
+		domains, serialBefore = lineage.Domains(), lineage.Serial()
+	}
+
+	ctx, span := tracing.Start(ctx, "certificate",
+		tracing.AttrCert.String(name),
+		tracing.AttrDomains.StringSlice(domains),
+		tracing.AttrTrigger.String(trigger),
+		tracing.AttrRunID.String(log.Data[logging.FieldRunID].(string)),
+		tracing.AttrCommand.String(args[0]),
+	)
+	defer func() { tracing.End(span, err, ErrorClass(err)) }()
+	log = tracing.WithSpan(ctx, log)
+
+	err = runCommand(ctx, log, certbotPath, args...)
+	auditLineageCommand(log, trigger, args, name, lineage, err)
+
+	record := newRecord(log, args[0], trigger, start, err)
+	record.Cert, record.Domains, record.SerialBefore = name, domains, serialBefore
+	if lineage, findErr := letsencrypt.FindLineage(globals.ConfigDir, name); findErr == nil && lineage != nil {
+		record.SerialAfter = lineage.Serial()
+	}
+	recordHistory(log, globals, record)
+	return err
+}
+
+// ServerArgs returns the arguments selecting the ACME server a configured certificate is requested from,
+// as generated for its initial request.
+func ServerArgs(certCfg config.Certificate, globals config.Globals) ([]string, error) {
+	return (&flags.StagingFlag{}).GenerateArgs(certCfg, globals)
+}
+
+// RecordedServerArgs returns the arguments selecting the ACME server recorded for a lineage, if any.
+func RecordedServerArgs(server string) []string {
+	if server == "" {
+		return nil
+	}
+	return []string{"--server", server}
+}
+
+// applyOrphanPolicy handles a recorded lineage that no configured certificate matches anymore. It returns the
+// change to make to the manager state, if any, which may be set even when it fails part way.
+func applyOrphanPolicy(ctx context.Context, log *logrus.Entry, policy string, globals config.Globals, certbotPath string, lineage letsencrypt.Lineage, recorded *state.Lineage, trigger string) (func(*state.State), error) {
+	switch policy {
+	case config.OrphanPolicyKeep:
+		log.Warnf("Lineage '%s' (%v) is no longer configured. Keeping it (orphan_policy = %s); certbot will keep renewing it.",
+			lineage.Name, recorded.Domains, policy)
+		return nil, nil
+
+	case config.OrphanPolicyStopRenewing:
+		if recorded.RenewalStopped {
+			return nil, nil
+		}
+		log.Warnf("Lineage '%s' (%v) is no longer configured. Stopping its renewal (orphan_policy = %s).",
+			lineage.Name, recorded.Domains, policy)
+		if err := letsencrypt.StopRenewal(lineage); err != nil {
+			return nil, fmt.Errorf("failed to stop renewal of orphaned lineage '%s': %w", lineage.Name, err)
+		}
+		return setRenewalStopped(lineage.Name, true), nil
+
+	case config.OrphanPolicyDelete, config.OrphanPolicyRevokeAndDelete:
+		log.Warnf("Lineage '%s' (%v) is no longer configured. Removing it (orphan_policy = %s).",
+			lineage.Name, recorded.Domains, policy)
+		// certbot only finds lineages through their renewal config.
+		if err := letsencrypt.ResumeRenewal(lineage); err != nil {
+			return nil, fmt.Errorf("failed to restore renewal config of orphaned lineage '%s': %w", lineage.Name, err)
+		}
+
+		var err error
+		if policy == config.OrphanPolicyDelete {
+			err = DeleteLineage(ctx, certbotPath, globals, lineage.Name, trigger)
+		} else {
+			err = RevokeLineage(ctx, certbotPath, globals, lineage.Name, RecordedServerArgs(recorded.Server), "superseded", true, trigger)
+		}
+		if err != nil {
+			return setRenewalStopped(lineage.Name, false), fmt.Errorf("failed to remove orphaned lineage '%s': %w", lineage.Name, err)
+		}
+		return forgetLineage(lineage.Name), nil
+
+	default:
+		return nil, fmt.Errorf("unknown orphan_policy '%s'", policy)
+	}
+}
+
+// destructivePolicy reports whether policy removes orphaned lineages.
+func destructivePolicy(policy string) bool {
+	return policy == config.OrphanPolicyDelete || policy == config.OrphanPolicyRevokeAndDelete
+}
+
+// isConfigured reports whether a configured certificate covers exactly the given domains.
+func isConfigured(cfg *config.Config, domains []string) bool {
+	for _, cert := range cfg.Certificates {
+		if letsencrypt.SameDomains(cert.Domains, domains) {
+			return true
+		}
+	}
+	return false
+}
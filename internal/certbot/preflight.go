@@ -0,0 +1,75 @@
+package certbot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/preflight"
+	"certbot-manager/internal/tracing"
+)
+
+func init() {
+	preflight.RegisterGlobal(checkCertbot)
+	preflight.RegisterGlobal(checkDirectories)
+	preflight.RegisterCertificate(checkArguments)
+}
+
+// checkCertbot checks that certbot can be run and its plugins listed.
+func checkCertbot(env preflight.Env) []preflight.Result {
+	const check = "certbot"
+	if env.CertbotErr != nil {
+		return []preflight.Result{preflight.Fail(check, "install certbot or point --certbot-path at it", "%v", env.CertbotErr)}
+	}
+	version, err := Version(env.CertbotPath)
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, "check that certbot runs for the user running certbot-manager", "%v", err)}
+	}
+	results := []preflight.Result{preflight.Pass(check, "certbot %s at %s", version, env.CertbotPath)}
+	if env.Plugins == nil {
+		results = append(results, preflight.Warn("certbot plugins", "run 'certbot plugins' to see why",
+			"the installed plugins couldn't be listed; plugin checks are skipped"))
+	}
+	return results
+}
+
+// checkDirectories checks that certbot's configuration directory and the manager state directory are writable.
+func checkDirectories(env preflight.Env) []preflight.Result {
+	return []preflight.Result{
+		preflight.CheckDirectory("config_dir", env.Config.Globals.ConfigDir),
+		preflight.CheckDirectory("state_dir", env.Config.Globals.StateDir),
+	}
+}
+
+// checkArguments checks that the certificate's certbot arguments can be built.
+func checkArguments(env preflight.Env, certCfg config.Certificate) []preflight.Result {
+	const check = "arguments"
+	args, err := NewArgsBuilder(certCfg, env.Config.Globals).Build()
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, "run 'certbot-manager plan' to see how the arguments are built", "%v", err)}
+	}
+	return []preflight.Result{preflight.Pass(check, "certbot %s ... (%d arguments)", args[0], len(args))}
+}
+
+// preflightCertificate runs the offline checks of `doctor` for cert before its certbot run, traced in a "preflight"
+// span under ctx. Failed checks are logged as warnings but don't stop the run: certbot's outcome is what counts.
+func preflightCertificate(ctx context.Context, log *logrus.Entry, cfg *config.Config, certbotPath string, cert config.Certificate) {
+	_, span := tracing.Start(ctx, "preflight")
+	results := preflight.RunCertificate(preflight.Env{Config: cfg, CertbotPath: certbotPath, Offline: true}, cert)
+	var failed []string
+	for _, result := range results {
+		if result.Status == preflight.StatusFail {
+			log.Warnf("Preflight check '%s' failed: %s (%s)", result.Check, result.Message, result.Hint)
+			failed = append(failed, result.Check)
+		}
+	}
+	span.SetAttributes(tracing.AttrPreflight.String(string(preflight.Worst(results))))
+	var err error
+	if len(failed) > 0 {
+		err = fmt.Errorf("preflight checks failed: %s", strings.Join(failed, ", "))
+	}
+	tracing.End(span, err, "")
+}
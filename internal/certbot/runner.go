@@ -2,15 +2,25 @@ package certbot
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"certbot-manager/internal/certbot/flags"
 	"certbot-manager/internal/config" // Import config package
+	"certbot-manager/internal/history"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/metrics"
+	"certbot-manager/internal/tracing"
 )
 
 // ValidateCertbotPath checks if the certbot command exists and is executable.
@@ -42,78 +52,344 @@ func ValidateCertbotPath(potentialPath string) (string, error) {
 	return resolvedPath, nil
 }
 
-// runCommand executes the certbot command with given arguments.
-func runCommand(executablePath string, args ...string) error {
-	cmd := exec.Command(executablePath, args...)
-	logrus.Debugf("Running command: %s %s", executablePath, strings.Join(args, " "))
+// running tracks the certbot command being executed, so health checks can tell a long or stuck run.
+var running struct {
+	sync.Mutex
+	command string
+	since   time.Time
+}
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
+// Running returns the certbot command being executed and when it started. ok is false if none is running.
+func Running() (command string, since time.Time, ok bool) {
+	running.Lock()
+	defer running.Unlock()
+	return running.command, running.since, !running.since.IsZero()
+}
+
+// attempts counts the consecutive failed runs of every certificate since the manager started, by certificate name.
+var attempts = struct {
+	sync.Mutex
+	failed map[string]int
+}{failed: map[string]int{}}
+
+// nextAttempt returns the attempt number of a certificate's next run: 1 after a success.
+func nextAttempt(name string) int {
+	attempts.Lock()
+	defer attempts.Unlock()
+	return attempts.failed[name] + 1
+}
 
-	err := cmd.Run() // Waits for completion
+// recordAttempt records the outcome of a certificate's run.
+func recordAttempt(name string, succeeded bool) {
+	attempts.Lock()
+	defer attempts.Unlock()
+	if succeeded {
+		delete(attempts.failed, name)
+	} else {
+		attempts.failed[name]++
+	}
+}
 
-	stdoutStr := strings.TrimSpace(stdoutBuf.String())
-	stderrStr := strings.TrimSpace(stderrBuf.String())
+// newRunLogger returns the entry for the log messages of one run of the runner, with a fresh run ID and what
+// triggered the run (see the history package).
+func newRunLogger(trigger string) *logrus.Entry {
+	return logging.Component("runner").WithFields(logrus.Fields{
+		logging.FieldRunID:   logging.NewRunID(),
+		logging.FieldTrigger: trigger,
+	})
+}
 
-	if len(stdoutStr) > 0 {
-		logrus.Debugf("Command stdout:\n---\n%s\n---", stdoutStr)
+// runCommand executes the certbot command with given arguments, logging to log.
+// Its duration and outcome are recorded in the metrics, labelled with the certbot command and authenticator, and
+// in a "certbot exec" span. certbot runs the pre, post and deploy hooks itself, so they are part of that span.
+// The run is also written to a transcript, and the hooks certbot reports running to the audit log.
+func runCommand(ctx context.Context, log *logrus.Entry, executablePath string, args ...string) (err error) {
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = args[0]
 	}
+	ctx, span := tracing.Start(ctx, "certbot exec", tracing.AttrCommand.String(subcommand))
+	log = tracing.WithSpan(ctx, log)
+	start := time.Now()
+	running.Lock()
+	running.command, running.since = subcommand, start
+	running.Unlock()
+	defer func() {
+		running.Lock()
+		running.command, running.since = "", time.Time{}
+		running.Unlock()
+		metrics.ObserveRun(subcommand, argAuthenticator(args), time.Since(start), ErrorClass(err))
+		tracing.End(span, err, ErrorClass(err))
+	}()
+
+	stdout, stderr, exitCode, err := execCommand(log, executablePath, args)
+	span.SetAttributes(tracing.AttrExitCode.Int(exitCode))
+	logging.WriteTranscript(logging.Transcript{
+		Name:     transcriptName(log, subcommand),
+		Command:  executablePath + " " + strings.Join(flags.MaskArgs(args), " "),
+		Fields:   log.Data,
+		Start:    start,
+		Duration: time.Since(start),
+		ExitCode: exitCode,
+		Stdout:   stdout,
+		Stderr:   stderr,
+	})
+	hooks := parseHooks(stderr + "\n" + stdout)
+	traceHooks(ctx, hooks)
+	auditHooks(log, subcommand, hooks)
+	return commandResult(log, stdout, stderr, exitCode, err)
+}
+
+// execCommand runs certbot and returns its trimmed output and exit code, -1 if it couldn't be started.
+func execCommand(log *logrus.Entry, executablePath string, args []string) (stdout, stderr string, exitCode int, err error) {
+	cmd := exec.Command(executablePath, args...)
+	log.Debugf("Running command: %s %s", executablePath, strings.Join(flags.MaskArgs(args), " "))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+	err = cmd.Run() // Waits for completion
 
+	stdout = strings.TrimSpace(stdoutBuf.String())
+	stderr = strings.TrimSpace(stderrBuf.String())
 	if err != nil {
+		exitCode = -1
 		var exitErr *exec.ExitError
-		exitCode := -1
 		if errors.As(err, &exitErr) {
 			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
 				exitCode = status.ExitStatus()
 			}
 		}
+	}
+	return stdout, stderr, exitCode, err
+}
+
+// commandResult logs the outcome of a certbot run and returns its error as a CommandError.
+func commandResult(log *logrus.Entry, stdout, stderr string, exitCode int, err error) error {
+	if len(stdout) > 0 {
+		log.Debugf("Command stdout:\n---\n%s\n---", stdout)
+	}
+
+	if err != nil {
 		errMsg := fmt.Sprintf("Command failed with error: %v", err)
-		if len(stderrStr) > 0 {
-			errMsg += fmt.Sprintf("\nStderr:\n---\n%s\n---", stderrStr)
+		if len(stderr) > 0 {
+			errMsg += fmt.Sprintf("\nStderr:\n---\n%s\n---", stderr)
 		}
-		logrus.Errorf("%s (Exit Code: %d)", errMsg, exitCode)
-		return fmt.Errorf("command execution failed (exit code %d): %w", exitCode, err)
+		log.Errorf("%s (Exit Code: %d)", errMsg, exitCode)
+		return &CommandError{ExitCode: exitCode, Stderr: stderr, Err: err}
 	}
 
-	logrus.Infof("Command finished successfully (Exit Code: 0)")
+	log.Infof("Command finished successfully (Exit Code: 0)")
 	return nil
 }
 
+// transcriptName names the transcript of a run after the certificate it is for. `certbot renew` covers every
+// lineage, so its transcript is named after the command.
+func transcriptName(log *logrus.Entry, subcommand string) string {
+	if cert, ok := log.Data[logging.FieldCert].(string); ok {
+		return cert
+	}
+	if subcommand == "renew" {
+		return subcommand
+	}
+	return ""
+}
+
+// argAuthenticator returns the authenticator selected by certbot arguments, empty if they don't select one.
+func argAuthenticator(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--webroot":
+			return "webroot"
+		case (arg == "-a" || arg == "--authenticator") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--authenticator="):
+			return strings.TrimPrefix(arg, "--authenticator=")
+		}
+	}
+	return ""
+}
+
+// DryRun runs certbot with the given arguments and --dry-run, which obtains a test certificate from the staging
+// server without saving anything. certbot only supports --dry-run for certonly, so "run" is tested as certonly.
+// Nothing changes either, so the run isn't written to a transcript, the metrics or the audit log.
+func DryRun(certbotPath string, args []string) error {
+	dryRunArgs := append([]string{}, args...)
+	if len(dryRunArgs) > 0 && dryRunArgs[0] == "run" {
+		dryRunArgs[0] = "certonly"
+	}
+	log := newRunLogger(history.TriggerManual)
+	if authenticator := argAuthenticator(args); authenticator != "" {
+		log = log.WithField(logging.FieldAuthenticator, authenticator)
+	}
+	stdout, stderr, exitCode, err := execCommand(log, certbotPath, append(dryRunArgs, "--dry-run"))
+	return commandResult(log, stdout, stderr, exitCode, err)
+}
+
 // RequestCertificates handles the initial 'certbot certonly' runs for all configured certificates.
-func RequestCertificates(cfg *config.Config, certbotPath string) bool { // Accepts *config.Config
-	logrus.Info("--- Initial Certificate Processing ---")
-	allRunsSuccessful := true
+// It returns the outcome of every certificate's run, nil on success, in the order of cfg.Certificates.
+// Every run is recorded in the run history with the given trigger, and traced in a "certificate" span under ctx.
+func RequestCertificates(ctx context.Context, cfg *config.Config, certbotPath string, trigger string) []error { // Accepts *config.Config
+	runLog := newRunLogger(trigger)
+	runLog.Info("--- Initial Certificate Processing ---")
+	results := make([]error, len(cfg.Certificates))
 
 	for i, cert := range cfg.Certificates {
-		logrus.Infof("Processing certificate request %d for domains: %v", i+1, cert.Domains)
-
-		// Create builder with specific cert config and global config
-		builder := NewArgsBuilder(cert, cfg.Globals)
-		args, err := builder.Build()
-		if err != nil {
-			logrus.Errorf("Error building arguments for cert #%d (%v): %v. Skipping.", i+1, cert.Domains, err)
-			allRunsSuccessful = false
-			continue
-		}
+		results[i] = requestCertificate(ctx, runLog, cfg, certbotPath, trigger, i, cert)
+	}
+	metrics.ObserveLineages(cfg.Globals.ConfigDir)
+	return results
+}
+
+// requestCertificate runs certbot for the certificate at index i of the configuration.
+func requestCertificate(ctx context.Context, runLog *logrus.Entry, cfg *config.Config, certbotPath, trigger string, i int, cert config.Certificate) (err error) {
+	name := certificateName(cert)
+	ctx, span := tracing.Start(ctx, "certificate",
+		tracing.AttrCert.String(name),
+		tracing.AttrDomains.StringSlice(cert.Domains),
+		tracing.AttrTrigger.String(trigger),
+		tracing.AttrRunID.String(runLog.Data[logging.FieldRunID].(string)),
+	)
+	defer func() { tracing.End(span, err, ErrorClass(err)) }()
+
+	log := tracing.WithSpan(ctx, runLog.WithFields(logrus.Fields{
+		logging.FieldCert:    name,
+		logging.FieldDomains: cert.Domains,
+		logging.FieldAttempt: nextAttempt(name),
+	}))
+	log.Infof("Processing certificate request %d for domains: %v", i+1, cert.Domains)
+	log.Debugf("Resolved settings for cert #%d (profiles: %v):", i+1, cert.Profiles)
+	for _, resolution := range cert.Resolve(cfg.Globals) {
+		log.Debugf("  %s", resolution)
+	}
 
-		err = runCommand(certbotPath, args...)
-		if err != nil {
-			logrus.Errorf("Failed initial certonly run for cert %d (%v): %v", i+1, cert.Domains, err)
-			allRunsSuccessful = false
+	start := time.Now()
+	lineage, serialBefore := lineageSerial(cfg.Globals.ConfigDir, cert.Domains)
+
+	// Create builder with specific cert config and global config
+	_, buildSpan := tracing.Start(ctx, "build args")
+	builder := NewArgsBuilder(cert, cfg.Globals)
+	args, err := builder.Build()
+	tracing.End(buildSpan, err, "")
+	if err != nil {
+		log.Errorf("Error building arguments for cert #%d (%v): %v. Skipping.", i+1, cert.Domains, err)
+		subcommand := flags.ResolveString(cert.Cmd, cfg.Globals.Cmd)
+		metrics.ObserveFailedRun(subcommand, ErrorClass(err))
+		metrics.ObserveCertificate(metricsName(name, lineage), false, time.Now())
+		recordAttempt(name, false)
+		record := newRecord(log, subcommand, trigger, start, err)
+		record.Cert, record.Domains, record.SerialBefore, record.SerialAfter = name, cert.Domains, serialBefore, serialBefore
+		if lineage != "" {
+			record.Cert = lineage
 		}
+		recordHistory(log, cfg.Globals, record)
+		return err
+	}
+	authenticator := argAuthenticator(args)
+	log = log.WithField(logging.FieldAuthenticator, authenticator)
+	span.SetAttributes(tracing.AttrAuthenticator.String(authenticator), tracing.AttrCommand.String(args[0]))
+	preflightCertificate(ctx, log, cfg, certbotPath, cert)
+
+	err = runCommand(ctx, log, certbotPath, args...)
+	recordAttempt(name, err == nil)
+	record := newRecord(log, args[0], trigger, start, err)
+	record.Cert, record.Domains, record.SerialBefore = name, cert.Domains, serialBefore
+	if lineageAfter, serialAfter := lineageSerial(cfg.Globals.ConfigDir, cert.Domains); lineageAfter != "" {
+		lineage = lineageAfter
+		record.Cert, record.SerialAfter = lineageAfter, serialAfter
+	}
+	metrics.ObserveCertificate(metricsName(name, lineage), err == nil, time.Now())
+	recordHistory(log, cfg.Globals, record)
+	if err != nil {
+		log.Errorf("Failed initial certonly run for cert %d (%v): %v", i+1, cert.Domains, err)
+		return err
+	}
+	return nil
+}
+
+// certificateName names a certificate after its first domain, which certbot names the lineage after.
+func certificateName(cert config.Certificate) string {
+	if len(cert.Domains) == 0 {
+		return ""
 	}
-	return allRunsSuccessful
+	return strings.ToLower(cert.Domains[0])
 }
 
-// RenewCertificates runs 'certbot renew'.
-func RenewCertificates(certbotPath string) error {
-	logrus.Info("Checking for certificate renewals...")
-	err := runCommand(certbotPath, "renew", "--quiet")
+// metricsName names a certificate in the metrics after its lineage, like the lineage expiry metrics, or after its
+// first domain (name) while it has no lineage.
+func metricsName(name, lineage string) string {
+	if lineage != "" {
+		return lineage
+	}
+	return name
+}
+
+// RenewCertificates runs 'certbot renew'. The run is recorded in the run history once per lineage, with the given
+// trigger and the lineage's serial before and after, and traced in a "renew" span under ctx. The outcome is
+// observed for the lineages of the configured certificates, see observeRenewal.
+func RenewCertificates(ctx context.Context, certbotPath string, cfg *config.Config, trigger string) (err error) {
+	globals := cfg.Globals
+	log := newRunLogger(trigger)
+	ctx, span := tracing.Start(ctx, "renew",
+		tracing.AttrTrigger.String(trigger),
+		tracing.AttrRunID.String(log.Data[logging.FieldRunID].(string)),
+	)
+	defer func() { tracing.End(span, err, ErrorClass(err)) }()
+	log = tracing.WithSpan(ctx, log)
+	log.Info("Checking for certificate renewals...")
+	start := time.Now()
+	before, _ := letsencrypt.Serials(globals.ConfigDir)
+	// No --quiet: the output is captured, and the audit log needs the lines about the hooks certbot ran.
+	args := append([]string{"renew"}, flags.ConfigDirArgs(globals)...)
+	err = runCommand(ctx, log, certbotPath, args...)
+	metrics.ObserveLineages(globals.ConfigDir)
+	recordRenewal(log, globals, trigger, start, before, err)
+	observeRenewal(cfg, before, err)
 	if err != nil {
-		logrus.Infof("Certbot renew command finished with potential issue: %v", err)
+		log.Infof("Certbot renew command finished with potential issue: %v", err)
 		return err
 	}
-	logrus.Info("Certbot renew command finished.")
+	log.Info("Certbot renew command finished.")
 	return nil
 }
+
+// renewFailedPattern matches the line certbot renew logs for each lineage it failed to renew.
+var renewFailedPattern = regexp.MustCompile(`(?m)Failed to renew certificate (\S+) with error`)
+
+// observeRenewal records the outcome of a `certbot renew` run in the metrics and attempt counts of the configured
+// certificates, named like their certonly runs: a lineage whose serial changed since before was renewed; one that
+// certbot reports as failed, or any that wasn't renewed if certbot failed without naming lineages, failed. Lineages
+// that weren't due are left alone, as are those the configuration doesn't cover.
+func observeRenewal(cfg *config.Config, before map[string]string, err error) {
+	lineages, lineagesErr := letsencrypt.Lineages(cfg.Globals.ConfigDir)
+	if lineagesErr != nil {
+		return
+	}
+	var failed map[string]bool
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		for _, match := range renewFailedPattern.FindAllStringSubmatch(cmdErr.Stderr, -1) {
+			if failed == nil {
+				failed = map[string]bool{}
+			}
+			failed[match[1]] = true
+		}
+	}
+
+	now := time.Now()
+	for _, cert := range cfg.Certificates {
+		lineage := letsencrypt.MatchLineage(lineages, cert.Domains)
+		if lineage == nil || lineage.Disabled {
+			continue
+		}
+		name := certificateName(cert)
+		switch serial := lineage.Serial(); {
+		case serial != "" && serial != before[lineage.Name]:
+			metrics.ObserveCertificate(lineage.Name, true, now)
+			recordAttempt(name, true)
+		case err != nil && (failed == nil || failed[lineage.Name]):
+			metrics.ObserveCertificate(lineage.Name, false, now)
+			recordAttempt(name, false)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Values accepted by the `args_mode` setting.
+const (
+	ArgsModeAppend  = "append"  // Certificate args are appended to the global args (default)
+	ArgsModeReplace = "replace" // Certificate args replace the global args
+)
+
+// ArgsModes lists the values accepted by the `args_mode` setting.
+var ArgsModes = []string{ArgsModeAppend, ArgsModeReplace}
+
+// ArgList holds extra certbot arguments. In the config file it is either an array of strings, passed through as is,
+// or a single string split into arguments with shell quoting rules.
+type ArgList []string
+
+// JSONSchema describes ArgList as either a string or an array of strings.
+func (ArgList) JSONSchema() *Schema {
+	return &Schema{AnyOf: []*Schema{
+		{Type: "string"},
+		{Type: "array", Items: &Schema{Type: "string"}},
+	}}
+}
+
+// argListHook decodes a string into an ArgList by splitting it into shell words.
+// It runs before Viper's default string-to-slice hook, which would otherwise split on commas.
+func argListHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(ArgList{}) || from.Kind() != reflect.String {
+		return data, nil
+	}
+	return SplitShellWords(data.(string))
+}
+
+// stringToSliceHook mirrors Viper's default hook: a string decoded into any slice is split on commas.
+func stringToSliceHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Slice {
+		return data, nil
+	}
+	if data.(string) == "" {
+		return []string{}, nil
+	}
+	return strings.Split(data.(string), ","), nil
+}
+
+// decodeHook is the mapstructure decode hook used to unmarshal the configuration.
+// It keeps Viper's default hooks and runs argListHook ahead of them.
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		argListHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringToSliceHook,
+	)
+}
+
+// SplitShellWords splits s into words like a POSIX shell would, without expanding anything:
+// words are separated by unquoted whitespace, single quotes preserve everything literally,
+// double quotes allow \" \\ \$ and \` escapes, and a backslash outside quotes escapes the next character.
+func SplitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, fmt.Errorf("trailing backslash in '%s'", s)
+			}
+			i++
+			current.WriteByte(s[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in '%s'", s)
+			}
+			current.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				current.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in '%s'", s)
+			}
+			inWord = true
+		default:
+			current.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
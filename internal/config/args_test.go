@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  string // Substring of the expected error
+	}{
+		{in: "", want: nil},
+		{in: "  \t\n ", want: nil},
+		{in: "--preferred-chain ISRG", want: []string{"--preferred-chain", "ISRG"}},
+		{in: "  a \t b\n\tc  ", want: []string{"a", "b", "c"}},
+		{in: "--preferred-chain 'ISRG Root X1'", want: []string{"--preferred-chain", "ISRG Root X1"}},
+		{in: `--preferred-chain "ISRG Root X1"`, want: []string{"--preferred-chain", "ISRG Root X1"}},
+		{in: `--opt="a b"c'd e'`, want: []string{"--opt=a bcd e"}},
+		{in: `''`, want: []string{""}},
+		{in: `a "" b`, want: []string{"a", "", "b"}},
+		{in: `'a\b "c" $d'`, want: []string{`a\b "c" $d`}},
+		{in: `"a \"b\" \\ \$c \` + "`" + `d\e"`, want: []string{"a \"b\" \\ $c `d\\e"}},
+		{in: `"it's"`, want: []string{"it's"}},
+		{in: `'say "hi"'`, want: []string{`say "hi"`}},
+		{in: `a\ b c\'d \"e\"`, want: []string{"a b", "c'd", `"e"`}},
+		{in: `\\`, want: []string{`\`}},
+		{in: `trailing\`, err: "trailing backslash"},
+		{in: `'open`, err: "unterminated single quote"},
+		{in: `"open`, err: "unterminated double quote"},
+		{in: `"escaped quote\"`, err: "unterminated double quote"},
+	}
+	for _, tt := range tests {
+		got, err := SplitShellWords(tt.in)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("SplitShellWords(%q) error = %v, want one containing %q", tt.in, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("SplitShellWords(%q) failed: %v", tt.in, err)
+		case tt.err == "" && !reflect.DeepEqual(got, tt.want):
+			t.Errorf("SplitShellWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"certbot-manager/internal/audit"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/tracing"
 )
 
 // Viper instance (package level)
@@ -16,68 +21,170 @@ var v *viper.Viper
 
 var (
 	Defaults = Default{
-		Staging:        true,
-		NoEffEmail:     true,
-		Cmd:            "certonly",
-		ConfigFilePath: "./config.toml",
-		CertbotPath:    "certbot",
-		LogLevel:       "info",
+		Staging:              true,
+		NoEffEmail:           true,
+		Cmd:                  "certonly",
+		ConfigFilePath:       "./config.toml",
+		CertbotPath:          "certbot",
+		LogLevel:             "info",
+		ConfigDir:            "/etc/letsencrypt",
+		OrphanPolicy:         OrphanPolicyKeep,
+		LogFormat:            logging.FormatText,
+		HistoryRetentionDays: 90,
+		ExpiryCheckCron:      "0 30 * * * *",
+		ExpiryWarningDays:    20,
+		ExpiryCriticalDays:   7,
 	}
 )
 
+// Values accepted by the `orphan_policy` setting, applied to lineages that certbot-manager created
+// but that are no longer in the configuration.
+const (
+	OrphanPolicyKeep            = "keep"              // Leave the lineage alone; certbot keeps renewing it
+	OrphanPolicyStopRenewing    = "stop-renewing"     // Disable its renewal config so `certbot renew` skips it
+	OrphanPolicyDelete          = "delete"            // Run `certbot delete`
+	OrphanPolicyRevokeAndDelete = "revoke-and-delete" // Run `certbot revoke --delete-after-revoke`
+)
+
+// OrphanPolicies lists the values accepted by the `orphan_policy` setting.
+var OrphanPolicies = []string{OrphanPolicyKeep, OrphanPolicyStopRenewing, OrphanPolicyDelete, OrphanPolicyRevokeAndDelete}
+
+// supportedFormats maps the config file extensions Load understands to the matching Viper config type.
+var supportedFormats = map[string]string{
+	".toml": "toml",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+}
+
 // Default holds default settings
 type Default struct {
-	Staging        bool
-	NoEffEmail     bool
-	Cmd            string
-	ConfigFilePath string
-	CertbotPath    string
-	LogLevel       string
+	Staging              bool
+	NoEffEmail           bool
+	Cmd                  string
+	ConfigFilePath       string
+	CertbotPath          string
+	LogLevel             string
+	ConfigDir            string
+	OrphanPolicy         string
+	LogFormat            string
+	HistoryRetentionDays int
+	ExpiryCheckCron      string
+	ExpiryWarningDays    int
+	ExpiryCriticalDays   int
 }
 
 // Config holds the application configuration
 type Config struct {
-	Globals      Globals       `mapstructure:"globals"`
-	Certificates []Certificate `mapstructure:"certificate"`
+	Globals      Globals                  `mapstructure:"globals"`
+	Profiles     map[string]CommonConfigs `mapstructure:"profile"`
+	Certificates []Certificate            `mapstructure:"certificate"`
+	Logging      logging.Config           `mapstructure:"logging"`
+	Tracing      tracing.Config           `mapstructure:"tracing"`
+	Audit        audit.Config             `mapstructure:"audit"`
 	CertbotPath  string
 	LogLevel     string
+	// ConfigFile is the path the configuration was read from.
+	ConfigFile string `mapstructure:"-"`
+	// LogLevelOverride names what takes precedence over globals.log_level, --log-level or
+	// CERTBOT_MANAGER_LOGLEVEL, empty if globals.log_level is unset or in effect.
+	LogLevelOverride string `mapstructure:"-"`
 }
 
 type CommonConfigs struct {
-	Cmd                 string `mapstructure:"cmd"`
-	Email               string `mapstructure:"email"`
-	WebrootPath         string `mapstructure:"webroot_path"`
-	Staging             *bool  `mapstructure:"staging"`
-	NoEffEmail          *bool  `mapstructure:"no_eff_email"`
-	KeyType             string `mapstructure:"key_type"`
-	InitialForceRenewal *bool  `mapstructure:"initial_force_renewal"`
-	Args                string `mapstructure:"args"`
-	Authenticator       string `mapstructure:"authenticator"`
+	Cmd         string `mapstructure:"cmd"`
+	Email       string `mapstructure:"email"`
+	WebrootPath string `mapstructure:"webroot_path"`
+	Staging     *bool  `mapstructure:"staging"`
+	// ACME directory URL of a CA other than Let's Encrypt, e.g. ZeroSSL or an internal step-ca; staging is then ignored
+	Server              string  `mapstructure:"server"`
+	NoEffEmail          *bool   `mapstructure:"no_eff_email"`
+	KeyType             string  `mapstructure:"key_type"`
+	InitialForceRenewal *bool   `mapstructure:"initial_force_renewal"`
+	Args                ArgList `mapstructure:"args"`
+	// How certificate args combine with global args: ArgsModeAppend (default) or ArgsModeReplace
+	ArgsMode      string `mapstructure:"args_mode"`
+	Authenticator string `mapstructure:"authenticator"`
 	// Seconds to wait for DNS propagation (only used if authenticator is dns-*)
 	DNSPropagationSeconds     *int   `mapstructure:"dns_propagation_seconds"`
 	CloudflareCredentialsPath string `mapstructure:"cloudflare_credentials_path"`
-	DuckDNSToken              string `mapstructure:"duckdns_token"`
+	DuckDNSToken              string `mapstructure:"duckdns_token" secret:"true"`
 }
 
 // Globals holds global settings
 type Globals struct {
-	RenewalCron   string `mapstructure:"renewal_cron"`
-	CommonConfigs `mapstructure:",squash"`
+	// Schedule of the renewal job, required unless the manager only runs with `run --once`
+	RenewalCron string `mapstructure:"renewal_cron"`
+	// Certbot's configuration directory, passed as --config-dir when it isn't the default
+	ConfigDir string `mapstructure:"config_dir"`
+	// Directory of the manager-owned state, defaults to <config_dir>/certbot-manager
+	StateDir     string `mapstructure:"state_dir"`
+	OrphanPolicy string `mapstructure:"orphan_policy"`
+	// Address of the HTTP listener serving /metrics, e.g. ":9300"; disabled when empty
+	ListenAddress string `mapstructure:"listen_address"`
+	// Format of the manager's log output, overridden by --log-format
+	LogFormat string `mapstructure:"log_format"`
+	// Level of the manager's log output, overridden by --log-level; a reload applies it
+	LogLevel string `mapstructure:"log_level"`
+	// Bearer token of the /admin endpoints, which are disabled when it's empty
+	AdminToken string `mapstructure:"admin_token" secret:"true"`
+	// Days the run history is kept; 0 keeps it forever
+	HistoryRetentionDays int `mapstructure:"history_retention_days"`
+	// Schedule of the expiry watchdog, which reads the certificate files themselves; disabled when empty
+	ExpiryCheckCron string `mapstructure:"expiry_check_cron"`
+	// Days before expiry at which the watchdog warns
+	ExpiryWarningDays int `mapstructure:"expiry_warning_days"`
+	// Days before expiry at which the watchdog reports a certificate as critical and /readyz fails
+	ExpiryCriticalDays int `mapstructure:"expiry_critical_days"`
+	// PEM certificate files the watchdog checks besides the lineages, e.g. certificates not managed by certbot
+	ExpiryCheckPaths []string `mapstructure:"expiry_check_paths"`
+	CommonConfigs    `mapstructure:",squash"`
+
+	// Sources records where each key was resolved from (SourceGlobal, SourceEnv or SourceDefault).
+	Sources map[string]string `mapstructure:"-"`
 }
 
 // Certificate represents a single certificate request
 type Certificate struct {
-	Domains       []string `mapstructure:"domains"`
+	Domains []string `mapstructure:"domains" jsonschema:"required"`
+	// Profiles names the [profile.<name>] tables this certificate inherits from, highest priority first.
+	Profiles      StringList `mapstructure:"profile"`
 	CommonConfigs `mapstructure:",squash"`
+
+	// Own holds the settings written in the certificate block itself, before profiles were applied.
+	Own CommonConfigs `mapstructure:"-"`
+	// Inherited holds the profiles referenced by Profiles, in the same order.
+	Inherited []Profile `mapstructure:"-"`
 }
 
-// Load initializes Viper and loads the configuration.
-func Load() (*Config, error) {
-	v = viper.New()
+// Profile is a named set of CommonConfigs that certificates can inherit from.
+type Profile struct {
+	Name string
+	CommonConfigs
+}
+
+// HelpFooter, when set, is called after the --help output, e.g. to list subcommands.
+var HelpFooter func()
+
+// flagSet is the flag set the configuration was last loaded with; Reload reuses it.
+var flagSet *pflag.FlagSet
+
+// AddFlags registers the flags that locate and complement the config file on fs.
+func AddFlags(fs *pflag.FlagSet) {
+	fs.StringP("config", "c", Defaults.ConfigFilePath, "Path to the configuration file (.toml, .yaml, .yml or .json)")
+	fs.String("certbot-path", Defaults.CertbotPath, "Path to the certbot executable")
+	fs.String("log-level", Defaults.LogLevel, "Logging level (debug, info, warn, error, fatal, panic)")
+	fs.String("log-format", Defaults.LogFormat, "Log output format (text, json, logfmt)")
+}
 
-	pflag.StringP("config", "c", Defaults.ConfigFilePath, "Path to the configuration file (e.g., /app/config.toml)")
-	pflag.String("certbot-path", Defaults.CertbotPath, "Path to the certbot executable")
-	pflag.String("log-level", Defaults.LogLevel, "Logging level (debug, info, warn, error, fatal, panic)")
+// globalFlags maps the [globals] keys that a command line flag overrides to the flag's name.
+var globalFlags = map[string]string{
+	"log_format": "log-format",
+}
+
+// Load parses the command line flags, initializes Viper and loads the configuration.
+func Load() (*Config, error) {
+	AddFlags(pflag.CommandLine)
 	help := pflag.BoolP("help", "h", false, "Show help message")
 
 	pflag.Parse()
@@ -88,39 +195,78 @@ func Load() (*Config, error) {
 		pflag.PrintDefaults()
 		fmt.Println("\nEnvironment Variables:")
 		fmt.Println("  CERTBOT_MANAGER_* : Can override config values (e.g., CERTBOT_MANAGER_GLOBALS_EMAIL).")
+		fmt.Println("                      Run 'certbot-manager env' to list them.")
+		if HelpFooter != nil {
+			HelpFooter()
+		}
 		os.Exit(0)
 	}
 
+	return LoadFrom(pflag.CommandLine)
+}
+
+// LoadFrom loads the configuration using the flags registered by AddFlags on fs, which must already be parsed.
+// Subcommands use it with their own flag sets.
+func LoadFrom(fs *pflag.FlagSet) (*Config, error) {
+	flagSet = fs
+	return load()
+}
+
+// Reload reads the configuration again, reusing the flags of the last Load or LoadFrom.
+func Reload() (*Config, error) {
+	return load()
+}
+
+// load builds a fresh Viper instance from the parsed flags, the environment and the config file.
+func load() (*Config, error) {
+	v = viper.New()
+
 	// Args
-	if err := v.BindPFlag("certbotPath", pflag.Lookup("certbot-path")); err != nil {
-		log.Printf("Warning: could not bind certbot-path flag: %v", err) // Use standard log before logrus setup
+	if err := v.BindPFlag("certbotPath", flagSet.Lookup("certbot-path")); err != nil {
+		logging.Component("config").Warnf("Could not bind certbot-path flag: %v", err)
 	}
-	if err := v.BindPFlag("logLevel", pflag.Lookup("log-level")); err != nil { // Bind log level flag
-		log.Printf("Warning: could not bind log-level flag: %v", err)
+	if err := v.BindPFlag("logLevel", flagSet.Lookup("log-level")); err != nil { // Bind log level flag
+		logging.Component("config").Warnf("Could not bind log-level flag: %v", err)
+	}
+
+	for key, name := range globalFlags {
+		if err := v.BindPFlag("globals."+key, flagSet.Lookup(name)); err != nil {
+			logging.Component("config").Warnf("Could not bind %s flag: %v", name, err)
+		}
 	}
 
 	// Defaults
-	v.SetDefault("globals.staging", Defaults.Staging)
-	v.SetDefault("globals.no_eff_email", Defaults.NoEffEmail)
-	v.SetDefault("globals.cmd", Defaults.Cmd)
+	for key, value := range globalDefaults() {
+		v.SetDefault("globals."+key, value)
+	}
 
 	// Env Vars
-	v.SetEnvPrefix("CERTBOT_MANAGER")
+	v.SetEnvPrefix(EnvPrefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 	var c Config
 	bindEnvsRecursive("globals", reflect.ValueOf(&c.Globals), v)
 
 	// Config File
-	configFilePath, _ := pflag.CommandLine.GetString("config") // Use the parsed value
+	configFilePath, _ := flagSet.GetString("config") // Use the parsed value
+
+	format, err := configFormat(configFilePath)
+	if err != nil {
+		return nil, err
+	}
 
 	if _, err := os.Stat(configFilePath); err == nil {
 		// File exists
 		v.SetConfigFile(configFilePath)
+		v.SetConfigType(format)
 		//log.Printf("Attempting to load configuration from: %s", configFilePath)
 
-		if err := v.ReadInConfig(); err != nil {
-			return nil, fmt.Errorf("failed to read config file '%s': %w", configFilePath, err)
+		settings, err := readConfigFile(configFilePath, format)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("failed to merge config file '%s': %w", configFilePath, err)
 		}
 		//log.Printf("Successfully loaded config file: %s", v.ConfigFileUsed())
 	} else if os.IsNotExist(err) {
@@ -132,14 +278,107 @@ func Load() (*Config, error) {
 	}
 
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(decodeHook())); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
 	}
 
 	// Validations
-	if cfg.Globals.RenewalCron == "" {
-		return nil, fmt.Errorf("globals.RenewalCron is empty")
+	if !isOneOf(cfg.Globals.OrphanPolicy, OrphanPolicies) {
+		return nil, fmt.Errorf("unknown globals.orphan_policy '%s' (options: %v)", cfg.Globals.OrphanPolicy, OrphanPolicies)
+	}
+	if !isOneOf(cfg.Globals.LogFormat, logging.Formats) {
+		return nil, fmt.Errorf("unknown globals.log_format '%s' (options: %v)", cfg.Globals.LogFormat, logging.Formats)
+	}
+	if cfg.Globals.LogLevel != "" {
+		if _, err := logrus.ParseLevel(cfg.Globals.LogLevel); err != nil {
+			return nil, fmt.Errorf("invalid globals.log_level: %w", err)
+		}
+		// --log-level, then CERTBOT_MANAGER_LOGLEVEL, take precedence over globals.log_level from any source.
+		_, env := os.LookupEnv(EnvPrefix + "_LOGLEVEL")
+		switch {
+		case flagSet.Changed("log-level"):
+			cfg.LogLevelOverride = "--log-level"
+		case env:
+			cfg.LogLevelOverride = EnvPrefix + "_LOGLEVEL"
+		default:
+			cfg.LogLevel = cfg.Globals.LogLevel
+		}
+	}
+	if cfg.Globals.ExpiryCriticalDays < 0 || cfg.Globals.ExpiryCriticalDays > cfg.Globals.ExpiryWarningDays {
+		return nil, fmt.Errorf("globals.expiry_critical_days (%d) must be between 0 and globals.expiry_warning_days (%d)",
+			cfg.Globals.ExpiryCriticalDays, cfg.Globals.ExpiryWarningDays)
+	}
+	if err := cfg.Logging.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tracing.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Globals.StateDir == "" {
+		cfg.Globals.StateDir = filepath.Join(cfg.Globals.ConfigDir, "certbot-manager")
+	}
+	cfg.ConfigFile = configFilePath
+
+	// Resolution
+	cfg.Globals.Sources = globalSources(v)
+	for i := range cfg.Certificates {
+		if err := cfg.Certificates[i].inherit(cfg.Profiles); err != nil {
+			return nil, fmt.Errorf("certificate #%d (%v): %w", i+1, cfg.Certificates[i].Domains, err)
+		}
 	}
 
 	return &cfg, nil
 }
+
+// readConfigFile parses the config file on its own Viper instance, so environment overrides and defaults
+// don't leak into the result, and expands ${VAR} references in its values before they are unmarshalled.
+func readConfigFile(path, format string) (map[string]any, error) {
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType(format)
+	if err := file.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file '%s': %w", path, err)
+	}
+
+	settings, err := interpolateSettings("", file.AllSettings())
+	if err != nil {
+		return nil, fmt.Errorf("failed to interpolate environment variables in config file '%s': %w", path, err)
+	}
+	return settings.(map[string]any), nil
+}
+
+// globalDefaults maps `[globals]` keys to their built-in default values.
+func globalDefaults() map[string]any {
+	return map[string]any{
+		"staging":                Defaults.Staging,
+		"no_eff_email":           Defaults.NoEffEmail,
+		"cmd":                    Defaults.Cmd,
+		"config_dir":             Defaults.ConfigDir,
+		"orphan_policy":          Defaults.OrphanPolicy,
+		"log_format":             Defaults.LogFormat,
+		"history_retention_days": Defaults.HistoryRetentionDays,
+		"expiry_check_cron":      Defaults.ExpiryCheckCron,
+		"expiry_warning_days":    Defaults.ExpiryWarningDays,
+		"expiry_critical_days":   Defaults.ExpiryCriticalDays,
+	}
+}
+
+// isOneOf reports whether value is one of options.
+func isOneOf(value string, options []string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// configFormat detects the config file format from its extension.
+func configFormat(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	format, ok := supportedFormats[ext]
+	if !ok {
+		return "", fmt.Errorf("unsupported config file extension '%s' for '%s' (supported: .toml, .yaml, .yml, .json)", ext, path)
+	}
+	return format, nil
+}
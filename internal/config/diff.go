@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff summarizes what changed from old to cfg, one line per change: the [globals] keys, certificates, profiles
+// and tables that were added, removed or changed. It names keys only, never values, so secrets stay out of it.
+func Diff(old, cfg *Config) []string {
+	var changes []string
+	if keys := changedKeys(old.Globals, cfg.Globals); len(keys) > 0 {
+		changes = append(changes, "globals changed: "+strings.Join(keys, ", "))
+	}
+	changes = append(changes, diffNamed("certificate", certificatesByName(old.Certificates), certificatesByName(cfg.Certificates))...)
+	changes = append(changes, diffNamed("profile", profilesByName(old.Profiles), profilesByName(cfg.Profiles))...)
+	for _, table := range []struct {
+		name     string
+		old, new any
+	}{
+		{"logging", old.Logging, cfg.Logging},
+		{"tracing", old.Tracing, cfg.Tracing},
+		{"audit", old.Audit, cfg.Audit},
+	} {
+		if !reflect.DeepEqual(table.old, table.new) {
+			changes = append(changes, fmt.Sprintf("[%s] changed", table.name))
+		}
+	}
+	return changes
+}
+
+// diffNamed compares the blocks of one kind, e.g. certificates, by name.
+func diffNamed(kind string, old, cfg map[string]any) []string {
+	names := make(map[string]bool, len(old)+len(cfg))
+	for name := range old {
+		names[name] = true
+	}
+	for name := range cfg {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, name := range sorted {
+		before, existed := old[name]
+		after, exists := cfg[name]
+		switch {
+		case !existed:
+			changes = append(changes, fmt.Sprintf("%s %s added", kind, name))
+		case !exists:
+			changes = append(changes, fmt.Sprintf("%s %s removed", kind, name))
+		default:
+			if keys := changedKeys(before, after); len(keys) > 0 {
+				changes = append(changes, fmt.Sprintf("%s %s changed: %s", kind, name, strings.Join(keys, ", ")))
+			}
+		}
+	}
+	return changes
+}
+
+// certificatesByName keys certificates by their first domain, numbering those that share it.
+func certificatesByName(certs []Certificate) map[string]any {
+	byName := make(map[string]any, len(certs))
+	for i, cert := range certs {
+		name := fmt.Sprintf("#%d", i+1)
+		if len(cert.Domains) > 0 {
+			name = strings.ToLower(cert.Domains[0])
+		}
+		if _, taken := byName[name]; taken {
+			name = fmt.Sprintf("%s#%d", name, i+1)
+		}
+		byName[name] = cert
+	}
+	return byName
+}
+
+func profilesByName(profiles map[string]CommonConfigs) map[string]any {
+	byName := make(map[string]any, len(profiles))
+	for name, profile := range profiles {
+		byName[name] = profile
+	}
+	return byName
+}
+
+// changedKeys returns the config keys whose values differ between two structs of the same type.
+func changedKeys(old, cfg any) []string {
+	values := map[string]reflect.Value{}
+	walkEnvVars("", "", reflect.ValueOf(old), func(key, _ string, _ reflect.StructField, val reflect.Value) {
+		values[key] = val
+	})
+	var keys []string
+	walkEnvVars("", "", reflect.ValueOf(cfg), func(key, _ string, _ reflect.StructField, val reflect.Value) {
+		if before, ok := values[key]; !ok || !reflect.DeepEqual(before.Interface(), val.Interface()) {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
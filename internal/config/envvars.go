@@ -3,22 +3,101 @@ package config
 import (
 	"fmt"
 	"github.com/spf13/viper"
-	"log"
+	"os"
 	"reflect"
 	"strings"
+
+	"certbot-manager/internal/logging"
 )
 
+// EnvPrefix prefixes every environment variable the configuration reads.
+const EnvPrefix = "CERTBOT_MANAGER"
+
+// EnvVar is an environment variable that overrides a configuration key, with the key's effective value.
+type EnvVar struct {
+	Name   string `json:"name"`   // e.g. CERTBOT_MANAGER_GLOBALS_EMAIL
+	Key    string `json:"key"`    // Viper key, e.g. globals.email
+	Type   string `json:"type"`   // string, bool, int, list or args (shell words)
+	Secret bool   `json:"secret"` // Value is masked
+	Value  string `json:"value"`  // Effective display value, empty if unset
+	Source string `json:"source"` // SourceFlag, SourceEnv, SourceGlobal or SourceDefault, empty if unset
+	// OverriddenBy names what takes precedence over this variable's key, e.g. --log-level for globals.log_level
+	OverriddenBy string `json:"overridden_by,omitempty"`
+}
+
+// EnvVars lists the environment variables the configuration reads, in declaration order: those backing the
+// --certbot-path and --log-level flags, then one per [globals] key as bound by bindEnvsRecursive.
+func (c *Config) EnvVars() []EnvVar {
+	logLevel := flagEnvVar("logLevel", "log-level", c.LogLevel)
+	if logLevel.Source == SourceDefault && c.Globals.LogLevel != "" {
+		logLevel.Source = c.Globals.Sources["log_level"] // The level comes from globals.log_level
+	}
+	vars := []EnvVar{flagEnvVar("certbotPath", "certbot-path", c.CertbotPath), logLevel}
+	walkEnvVars("globals", EnvPrefix+"_GLOBALS", reflect.ValueOf(&c.Globals), func(key, envVar string, field reflect.StructField, val reflect.Value) {
+		secret := field.Tag.Get("secret") == "true"
+		layer := layerOf(c.Globals.Sources[strings.TrimPrefix(key, "globals.")], val, secret)
+		if layer.Set && layer.Source == "" {
+			layer.Source = SourceDefault // Derived at load time, e.g. state_dir
+		}
+		if !layer.Set {
+			layer.Source = ""
+		}
+		ev := EnvVar{
+			Name:   envVar,
+			Key:    key,
+			Type:   envVarType(field.Type),
+			Secret: secret,
+			Value:  layer.Value,
+			Source: layer.Source,
+		}
+		if key == "globals.log_level" && layer.Set {
+			ev.OverriddenBy = c.LogLevelOverride
+		}
+		vars = append(vars, ev)
+	})
+	return vars
+}
+
+// flagEnvVar describes the variable Viper's AutomaticEnv maps to a flag-backed key. The flag wins when given.
+func flagEnvVar(key, flag, value string) EnvVar {
+	name := EnvPrefix + "_" + strings.ToUpper(key)
+	source := SourceDefault
+	if flagSet != nil && flagSet.Changed(flag) {
+		source = SourceFlag
+	} else if _, ok := os.LookupEnv(name); ok {
+		source = SourceEnv
+	}
+	return EnvVar{Name: name, Key: key, Type: "string", Value: value, Source: source}
+}
+
+// envVarType names the type an environment variable is decoded into.
+func envVarType(typ reflect.Type) string {
+	if typ == reflect.TypeOf(ArgList{}) {
+		return "args"
+	}
+	if typ.Kind() == reflect.Slice {
+		return "list"
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind().String()
+}
+
 // bindEnvsRecursive DFS traverses a struct, building Viper keys and ENV var names, then binds them.
 // viperKeyPrefix: e.g., "globals", "certificates.0" (accumulated dot-separated path for Viper)
 // envVarPrefix: e.g., "CERTBOT_MANAGER_GLOBALS_", "CERTBOT_MANAGER_CERTIFICATES_0_" (accumulated underscore-separated for ENV)
 // val: The reflect.Value of the struct/field to inspect.
 func bindEnvsRecursive(viperKeyPrefix string, val reflect.Value, v *viper.Viper) {
 	envVarPrefix := fmt.Sprintf("%s_%s", v.GetEnvPrefix(), strings.ToUpper(viperKeyPrefix))
-	bindEnvsRecursive2(viperKeyPrefix, envVarPrefix, val, v)
+	walkEnvVars(viperKeyPrefix, envVarPrefix, val, func(key, envVar string, _ reflect.StructField, _ reflect.Value) {
+		_ = v.BindEnv(key, envVar)
+	})
 }
 
-// bindEnvsRecursive2 is a helper function that does the actual work of binding ENV vars to Viper keys.
-func bindEnvsRecursive2(viperKeyPrefix string, envVarPrefix string, val reflect.Value, v *viper.Viper) {
+// walkEnvVars is a helper function that does the actual work of deriving ENV var names from Viper keys.
+// bind is called for every simple field with its Viper key, its ENV var name, the struct field and its value.
+func walkEnvVars(viperKeyPrefix string, envVarPrefix string, val reflect.Value, bind func(key, envVar string, field reflect.StructField, val reflect.Value)) {
 	// Dereference pointer if val is a pointer to a struct
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() { // Important: If pointer is nil, can't proceed
@@ -71,7 +150,7 @@ func bindEnvsRecursive2(viperKeyPrefix string, envVarPrefix string, val reflect.
 			// they are part of the parent. So, we don't add the squashed field's name
 			// to the path/prefix, but recurse on its value.
 			if fieldVal.Kind() == reflect.Struct || (fieldVal.Kind() == reflect.Ptr && !fieldVal.IsNil() && fieldVal.Elem().Kind() == reflect.Struct) {
-				bindEnvsRecursive2(viperKeyPrefix, envVarPrefix, fieldVal, v)
+				walkEnvVars(viperKeyPrefix, envVarPrefix, fieldVal, bind)
 			}
 		} else {
 			// For regular (non-squashed) fields:
@@ -89,15 +168,14 @@ func bindEnvsRecursive2(viperKeyPrefix string, envVarPrefix string, val reflect.
 			if envVarPrefix == "" { // Top-level field, use only APP_PREFIX + SEGMENT
 				// This case is for fields directly in the `Config` struct if they were bound directly
 				// For our setup, we usually start recursion with a base prefix.
-				// The global `v.GetEnvPrefix()` should be the app's main prefix.
-				nextEnvVarName = v.GetEnvPrefix() + "_" + envSegment // Assumes EnvPrefix is set
+				nextEnvVarName = EnvPrefix + "_" + envSegment
 			} else {
 				nextEnvVarName = envVarPrefix + "_" + envSegment
 			}
 
 			// Recurse for nested structs (that are not squashed)
 			if fieldVal.Kind() == reflect.Struct || (fieldVal.Kind() == reflect.Ptr && !fieldVal.IsNil() && fieldVal.Elem().Kind() == reflect.Struct) {
-				bindEnvsRecursive2(nextViperKeyPath, nextEnvVarName, fieldVal, v)
+				walkEnvVars(nextViperKeyPath, nextEnvVarName, fieldVal, bind)
 			} else if fieldVal.Kind() == reflect.Slice && fieldVal.Type().Elem().Kind() == reflect.Struct {
 				// --- Advanced: Handle Slices of Structs ---
 				// This would bind CERTBOT_MANAGER_CERTIFICATES_0_CMD, CERTBOT_MANAGER_CERTIFICATES_1_CMD etc.
@@ -117,11 +195,11 @@ func bindEnvsRecursive2(viperKeyPrefix string, envVarPrefix string, val reflect.
 				//      bindEnvsRecursive(elemViperPath, elemEnvPrefix, dummyElem)
 				//  }
 				// }
-				log.Printf("Skipping automatic ENV binding for slice elements in '%s'. Configure via TOML or a single ENV var with JSON/YAML string.", nextViperKeyPath)
+				logging.Component("config").Debugf("Skipping automatic ENV binding for slice elements in '%s'. Configure via TOML or a single ENV var with JSON/YAML string.", nextViperKeyPath)
 
 			} else {
 				// Bind ENV for simple (non-struct, non-slice) fields
-				_ = v.BindEnv(nextViperKeyPath, nextEnvVarName)
+				bind(nextViperKeyPath, nextEnvVarName, field, fieldVal)
 			}
 		}
 	}
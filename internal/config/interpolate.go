@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// interpolateSettings expands environment variable references in every string value of a parsed config file,
+// including values nested in tables and arrays. path is the key path used in error messages.
+// All failures are collected so a single run reports every missing variable.
+func interpolateSettings(path string, value any) (any, error) {
+	switch typed := value.(type) {
+	case string:
+		expanded, err := interpolate(typed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return expanded, nil
+	case map[string]any:
+		var errs []error
+		for key, item := range typed {
+			expanded, err := interpolateSettings(joinKey(path, key), item)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			typed[key] = expanded
+		}
+		return typed, errors.Join(errs...)
+	case []any:
+		var errs []error
+		for i, item := range typed {
+			expanded, err := interpolateSettings(fmt.Sprintf("%s[%d]", path, i), item)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			typed[i] = expanded
+		}
+		return typed, errors.Join(errs...)
+	case []map[string]any:
+		var errs []error
+		for i, item := range typed {
+			if _, err := interpolateSettings(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return typed, errors.Join(errs...)
+	default:
+		return value, nil
+	}
+}
+
+// interpolate expands environment variable references in s:
+//
+//	${NAME}             value of NAME, empty if unset
+//	${NAME:-default}    value of NAME, or default if NAME is unset or empty
+//	${NAME:?message}    value of NAME, or an error with message if NAME is unset or empty
+//	$$                  a literal '$'
+//
+// A '$' that starts none of the above is kept as is.
+func interpolate(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference '%s'", s[i:])
+			}
+			expanded, err := expandReference(s[i+2 : i+2+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(expanded)
+			i += end + 2
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// expandReference resolves the body of a ${...} reference.
+func expandReference(ref string) (string, error) {
+	name, operator, operand := ref, "", ""
+	if idx := strings.Index(ref, ":"); idx >= 0 {
+		name, operator, operand = ref[:idx], ref[idx:min(idx+2, len(ref))], ref[min(idx+2, len(ref)):]
+	}
+	if !isValidEnvName(name) {
+		return "", fmt.Errorf("invalid variable name in '${%s}'", ref)
+	}
+
+	value := os.Getenv(name)
+	switch operator {
+	case "":
+		return value, nil
+	case ":-":
+		if value == "" {
+			return operand, nil
+		}
+		return value, nil
+	case ":?":
+		if value == "" {
+			if operand == "" {
+				operand = "not set"
+			}
+			return "", fmt.Errorf("required variable %s: %s", name, operand)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported operator '%s' in '${%s}' (supported: ':-', ':?')", operator, ref)
+	}
+}
+
+// isValidEnvName reports whether name is a POSIX environment variable name.
+func isValidEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		isLetter := r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinKey appends key to a dot-separated config path.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("CM_TEST_EMAIL", "admin@example.org")
+	t.Setenv("CM_TEST_EMPTY", "")
+	t.Setenv("CM_TEST_QUOTED", `it's "quoted" ${CM_TEST_EMAIL} $$`)
+
+	tests := []struct {
+		in, want string
+		err      string // Substring of the expected error
+	}{
+		{in: "no variables", want: "no variables"},
+		{in: "${CM_TEST_EMAIL}", want: "admin@example.org"},
+		{in: "mailto:${CM_TEST_EMAIL}!", want: "mailto:admin@example.org!"},
+		{in: "${CM_TEST_EMAIL}${CM_TEST_EMAIL}", want: "admin@example.orgadmin@example.org"},
+		{in: "${CM_TEST_QUOTED}", want: `it's "quoted" ${CM_TEST_EMAIL} $$`},
+		{in: "'${CM_TEST_EMAIL}'", want: "'admin@example.org'"},
+		{in: "${CM_TEST_UNSET}", want: ""},
+		{in: "$$", want: "$"},
+		{in: "$${CM_TEST_EMAIL}", want: "${CM_TEST_EMAIL}"},
+		{in: "$$$${CM_TEST_EMAIL}", want: "$${CM_TEST_EMAIL}"},
+		{in: "$$${CM_TEST_EMAIL}", want: "$admin@example.org"},
+		{in: "cost: $5 $", want: "cost: $5 $"},
+		{in: "$CM_TEST_EMAIL", want: "$CM_TEST_EMAIL"},
+		{in: "${CM_TEST_UNSET:-fallback}", want: "fallback"},
+		{in: "${CM_TEST_EMPTY:-fallback}", want: "fallback"},
+		{in: "${CM_TEST_EMAIL:-fallback}", want: "admin@example.org"},
+		{in: "${CM_TEST_UNSET:-}", want: ""},
+		{in: "${CM_TEST_UNSET:-a:-b}", want: "a:-b"},
+		{in: "${CM_TEST_EMAIL:?required}", want: "admin@example.org"},
+		{in: "${CM_TEST_UNSET:?set the email}", err: "required variable CM_TEST_UNSET: set the email"},
+		{in: "${CM_TEST_EMPTY:?}", err: "required variable CM_TEST_EMPTY: not set"},
+		{in: "${CM_TEST_UNSET", err: "unterminated variable reference"},
+		{in: "${}", err: "invalid variable name"},
+		{in: "${1ABC}", err: "invalid variable name"},
+		{in: "${CM-TEST}", err: "invalid variable name"},
+		{in: "${CM_TEST_EMAIL:=x}", err: "unsupported operator ':='"},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.in)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("interpolate(%q) error = %v, want one containing %q", tt.in, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("interpolate(%q) failed: %v", tt.in, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("interpolate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateSettings(t *testing.T) {
+	t.Setenv("CM_TEST_DOMAIN", "example.org")
+
+	settings := map[string]any{
+		"email": "admin@${CM_TEST_DOMAIN}",
+		"globals": map[string]any{
+			"args":  []any{"--preferred-chain", "${CM_TEST_CHAIN:-ISRG Root X1}"},
+			"count": int64(3),
+		},
+		"certificate": []map[string]any{
+			{"domains": []any{"${CM_TEST_DOMAIN}", "www.${CM_TEST_DOMAIN}"}},
+		},
+	}
+	got, err := interpolateSettings("", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"email": "admin@example.org",
+		"globals": map[string]any{
+			"args":  []any{"--preferred-chain", "ISRG Root X1"},
+			"count": int64(3),
+		},
+		"certificate": []map[string]any{
+			{"domains": []any{"example.org", "www.example.org"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("interpolateSettings = %#v, want %#v", got, want)
+	}
+
+	// Every missing variable is reported, with the path of its setting.
+	_, err = interpolateSettings("", map[string]any{
+		"email":       "${CM_TEST_UNSET_A:?}",
+		"certificate": []map[string]any{{"webroot_path": "${CM_TEST_UNSET_B:?}"}},
+	})
+	if err == nil {
+		t.Fatal("interpolateSettings with missing variables succeeded")
+	}
+	for _, part := range []string{"email: required variable CM_TEST_UNSET_A", "certificate[0].webroot_path: required variable CM_TEST_UNSET_B"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q doesn't mention %q", err, part)
+		}
+	}
+}
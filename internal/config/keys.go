@@ -0,0 +1,31 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Key describes a setting of a [[certificate]] block.
+type Key struct {
+	Name   string // Config key, e.g. webroot_path
+	Type   string // string, bool, int, list or args (shell words)
+	Secret bool
+}
+
+// CertificateKeys lists the keys a [[certificate]] block accepts, in declaration order.
+func CertificateKeys() []Key {
+	var keys []Key
+	walkEnvVars("", "", reflect.ValueOf(Certificate{}), func(key, _ string, field reflect.StructField, _ reflect.Value) {
+		keys = append(keys, Key{Name: key, Type: envVarType(field.Type), Secret: field.Tag.Get("secret") == "true"})
+	})
+	return keys
+}
+
+// CertificateKey looks up a key of CertificateKeys by name.
+func CertificateKey(name string) (Key, bool) {
+	for _, key := range CertificateKeys() {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Source labels describing where a resolved setting came from.
+// Profile layers are labelled "profile:<name>", see ProfileSource.
+const (
+	SourceCert    = "cert"
+	SourceGlobal  = "global"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+	SourceFlag    = "flag" // Command line flag, only for --certbot-path, --log-level and --log-format
+)
+
+// MaskedValue replaces secret values in any human-readable output.
+const MaskedValue = "********"
+
+// ProfileSource returns the source label of the named profile.
+func ProfileSource(name string) string {
+	return "profile:" + name
+}
+
+// StringList is a list of strings that may also be written as a single string in the config file.
+// Viper's weakly typed decoding turns `profile = "name"` into a one-element list.
+type StringList []string
+
+// JSONSchema describes StringList as either a string or an array of strings.
+func (StringList) JSONSchema() *Schema {
+	return &Schema{AnyOf: []*Schema{
+		{Type: "string"},
+		{Type: "array", Items: &Schema{Type: "string"}},
+	}}
+}
+
+// Layer is the value a single configuration layer holds for a setting.
+type Layer struct {
+	Source string // SourceCert, ProfileSource(name), SourceGlobal, SourceEnv or SourceDefault
+	Value  string // Display value, masked if the setting is a secret
+	Set    bool
+}
+
+// Resolution describes how a CommonConfigs key was resolved for a certificate.
+type Resolution struct {
+	Key    string
+	Value  string // Effective display value, masked if the setting is a secret
+	Source string // Source of the layer that provided Value, empty if no layer set it
+	Chain  []Layer
+}
+
+// String renders the resolution and its full chain, e.g.
+// "key_type = ecdsa [profile:prod] (cert: unset -> profile:prod: ecdsa -> global: rsa)".
+func (r Resolution) String() string {
+	steps := make([]string, 0, len(r.Chain))
+	for _, layer := range r.Chain {
+		value := "unset"
+		if layer.Set {
+			value = layer.Value
+		}
+		steps = append(steps, fmt.Sprintf("%s: %s", layer.Source, value))
+	}
+	value, source := r.Value, r.Source
+	if source == "" {
+		value, source = "unset", "none"
+	}
+	return fmt.Sprintf("%s = %s [%s] (%s)", r.Key, value, source, strings.Join(steps, " -> "))
+}
+
+// Resolve explains every CommonConfigs key of the certificate: the effective value, the layer it came from and
+// the value each layer holds, in resolution order (certificate, profiles, globals).
+// Defaults are applied to globals at load time, so they appear as the globals layer labelled SourceDefault.
+func (c Certificate) Resolve(globals Globals) []Resolution {
+	typ := reflect.TypeOf(CommonConfigs{})
+	own := reflect.ValueOf(c.Own)
+	global := reflect.ValueOf(globals.CommonConfigs)
+
+	resolutions := make([]Resolution, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := commonKey(field)
+		secret := field.Tag.Get("secret") == "true"
+
+		chain := []Layer{layerOf(SourceCert, own.Field(i), secret)}
+		for _, profile := range c.Inherited {
+			chain = append(chain, layerOf(ProfileSource(profile.Name), reflect.ValueOf(profile.CommonConfigs).Field(i), secret))
+		}
+		globalSource := globals.Sources[key]
+		if globalSource == "" {
+			globalSource = SourceGlobal
+		}
+		chain = append(chain, layerOf(globalSource, global.Field(i), secret))
+
+		resolution := Resolution{Key: key, Chain: chain}
+		for _, layer := range chain {
+			if layer.Set {
+				resolution.Value = layer.Value
+				resolution.Source = layer.Source
+				break
+			}
+		}
+		if key == "args" {
+			resolution.Value, resolution.Source = c.resolveArgs(globals, chain)
+		}
+		resolutions = append(resolutions, resolution)
+	}
+	return resolutions
+}
+
+// Settings resolves every setting that applies to the certificate, keyed by config key: the CommonConfigs keys
+// as explained by Resolve, the globals-only keys and the certificate's domains.
+func (c Certificate) Settings(globals Globals) map[string]Resolution {
+	settings := map[string]Resolution{
+		"domains": {Key: "domains", Value: strings.Join(c.Domains, ","), Source: SourceCert},
+	}
+
+	typ := reflect.TypeOf(globals)
+	val := reflect.ValueOf(globals)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := commonKey(field)
+		if key == "-" || field.Type == reflect.TypeOf(CommonConfigs{}) {
+			continue
+		}
+		layer := layerOf(globals.Sources[key], val.Field(i), field.Tag.Get("secret") == "true")
+		resolution := Resolution{Key: key, Chain: []Layer{layer}}
+		if layer.Set {
+			resolution.Value, resolution.Source = layer.Value, layer.Source
+		}
+		settings[key] = resolution
+	}
+
+	for _, resolution := range c.Resolve(globals) {
+		settings[resolution.Key] = resolution
+	}
+	return settings
+}
+
+// resolveArgs renders the effective args, which combine the global and certificate layers in append mode.
+func (c Certificate) resolveArgs(globals Globals, chain []Layer) (string, string) {
+	certLayer, globalLayer := chain[0], chain[len(chain)-1]
+	for _, layer := range chain[:len(chain)-1] {
+		if layer.Set {
+			certLayer = layer
+			break
+		}
+	}
+
+	mode := c.ArgsMode
+	if mode == "" {
+		mode = globals.ArgsMode
+	}
+	if mode == ArgsModeReplace || !globalLayer.Set {
+		if !certLayer.Set {
+			return "", ""
+		}
+		return certLayer.Value, certLayer.Source
+	}
+	if !certLayer.Set {
+		return globalLayer.Value, globalLayer.Source
+	}
+	return strings.Join([]string{globalLayer.Value, certLayer.Value}, " "), globalLayer.Source + "+" + certLayer.Source
+}
+
+// inherit records the certificate's own settings and fills its unset CommonConfigs fields from the
+// referenced profiles, first profile winning. Flag generators then only need to look at certificate and globals.
+func (c *Certificate) inherit(profiles map[string]CommonConfigs) error {
+	c.Own = c.CommonConfigs
+	c.Inherited = nil
+
+	for _, name := range c.Profiles {
+		// Viper lowercases map keys, so profile names are matched case-insensitively.
+		normalized := strings.ToLower(name)
+		profile, ok := profiles[normalized]
+		if !ok {
+			known := make([]string, 0, len(profiles))
+			for k := range profiles {
+				known = append(known, k)
+			}
+			return fmt.Errorf("unknown profile '%s' (known: %v)", name, known)
+		}
+		c.Inherited = append(c.Inherited, Profile{Name: normalized, CommonConfigs: profile})
+		mergeCommon(&c.CommonConfigs, profile)
+	}
+	return nil
+}
+
+// mergeCommon sets every unset field of dst to the corresponding field of src.
+func mergeCommon(dst *CommonConfigs, src CommonConfigs) {
+	dstVal := reflect.ValueOf(dst).Elem()
+	srcVal := reflect.ValueOf(src)
+	for i := 0; i < dstVal.NumField(); i++ {
+		if dstVal.Field(i).IsZero() && !srcVal.Field(i).IsZero() {
+			dstVal.Field(i).Set(srcVal.Field(i))
+		}
+	}
+}
+
+// globalSources reports, for every `[globals]` key, whether its value came from an environment variable,
+// the config file or a built-in default. Keys set nowhere are omitted.
+func globalSources(v *viper.Viper) map[string]string {
+	defaults := globalDefaults()
+	keys := globalKeys()
+
+	sources := make(map[string]string, len(keys))
+	for _, key := range keys {
+		envVar := fmt.Sprintf("%s_GLOBALS_%s", v.GetEnvPrefix(), strings.ToUpper(key))
+		if name, ok := globalFlags[key]; ok && flagSet != nil && flagSet.Changed(name) {
+			sources[key] = SourceFlag
+		} else if _, ok := os.LookupEnv(envVar); ok {
+			sources[key] = SourceEnv
+		} else if v.InConfig("globals." + key) {
+			sources[key] = SourceGlobal
+		} else if _, ok := defaults[key]; ok {
+			sources[key] = SourceDefault
+		}
+	}
+	return sources
+}
+
+// globalKeys returns the config keys of Globals, including the squashed CommonConfigs ones.
+func globalKeys() []string {
+	var keys []string
+	typ := reflect.TypeOf(Globals{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := commonKey(field)
+		switch {
+		case key == "-":
+		case field.Type == reflect.TypeOf(CommonConfigs{}):
+			common := field.Type
+			for j := 0; j < common.NumField(); j++ {
+				keys = append(keys, commonKey(common.Field(j)))
+			}
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// commonKey returns the config key of a CommonConfigs field.
+func commonKey(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+}
+
+// layerOf renders a single CommonConfigs field value as a Layer.
+func layerOf(source string, val reflect.Value, secret bool) Layer {
+	layer := Layer{Source: source}
+	if val.IsZero() {
+		return layer
+	}
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	layer.Set = true
+	if args, ok := val.Interface().(ArgList); ok {
+		layer.Value = strings.Join(args, " ")
+	} else {
+		layer.Value = fmt.Sprint(val.Interface())
+	}
+	if secret {
+		layer.Value = MaskedValue
+	}
+	return layer
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaDraft is the JSON Schema dialect emitted by GenerateSchema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema needed to describe the configuration file.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"` // bool or *Schema
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Default              any                `json:"default,omitempty"`
+}
+
+// schemaProvider is implemented by config types whose schema can't be derived from their Go kind alone.
+type schemaProvider interface {
+	JSONSchema() *Schema
+}
+
+// GenerateSchema builds the JSON Schema of the configuration file by reflecting over Config.
+// enums maps a mapstructure key (e.g. "cmd") to its allowed values; it is supplied by the caller so the
+// values come from the packages that actually validate them.
+func GenerateSchema(enums map[string][]string) *Schema {
+	root := schemaForType(reflect.TypeOf(Config{}), enums)
+	root.Schema = SchemaDraft
+	root.Title = "Certbot Manager configuration"
+	// Editors and language servers read "$schema" from the document itself, so allow it at the top level.
+	root.Properties["$schema"] = &Schema{Type: "string"}
+
+	if globals, ok := root.Properties["globals"]; ok {
+		for key, value := range globalDefaults() {
+			if prop, ok := globals.Properties[key]; ok {
+				prop.Default = value
+			}
+		}
+	}
+
+	return root
+}
+
+// schemaForType maps a Go type to its schema, following the same mapstructure conventions as bindEnvsRecursive.
+func schemaForType(typ reflect.Type, enums map[string][]string) *Schema {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if provider, ok := reflect.Zero(typ).Interface().(schemaProvider); ok {
+		return provider.JSONSchema()
+	}
+	if typ == reflect.TypeOf(time.Duration(0)) {
+		return &Schema{Type: "string", Description: `Duration such as "90s", "24h"`}
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+		addStructProperties(s, typ, enums)
+		sort.Strings(s.Required)
+		return s
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaForType(typ.Elem(), enums)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaForType(typ.Elem(), enums)}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// addStructProperties adds the fields of typ to s, flattening squashed embedded structs.
+func addStructProperties(s *Schema, typ reflect.Type, enums map[string][]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tagParts := strings.Split(field.Tag.Get("mapstructure"), ",")
+		key := tagParts[0]
+		if key == "-" {
+			continue
+		}
+
+		isSquashed := false
+		for _, part := range tagParts[1:] {
+			if part == "squash" {
+				isSquashed = true
+				break
+			}
+		}
+		if isSquashed {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			addStructProperties(s, embedded, enums)
+			continue
+		}
+
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+
+		prop := schemaForType(field.Type, enums)
+		if values, ok := enums[key]; ok {
+			prop.Enum = values
+		}
+		s.Properties[key] = prop
+
+		for _, option := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+			if option == "required" {
+				s.Required = append(s.Required, key)
+			}
+		}
+	}
+}
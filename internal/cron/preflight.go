@@ -0,0 +1,31 @@
+package cron
+
+import (
+	"time"
+
+	"certbot-manager/internal/preflight"
+)
+
+func init() { preflight.RegisterGlobal(checkRenewalCron) }
+
+// checkRenewalCron checks that the renewal schedule parses and fires often enough.
+func checkRenewalCron(env preflight.Env) []preflight.Result {
+	const check = "renewal_cron"
+	expression := env.Config.Globals.RenewalCron
+	if expression == "" {
+		return []preflight.Result{preflight.Warn(check, "set renewal_cron, e.g. \"0 0 0,12 * * *\", unless you only use 'run --once'",
+			"not set")}
+	}
+	runs, err := NextRuns(expression, time.Now(), 2)
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, "use a six-field expression with seconds, e.g. \"0 0 0,12 * * *\"", "%v", err)}
+	}
+	if len(runs) == 0 {
+		return []preflight.Result{preflight.Fail(check, "use an expression that fires regularly", "'%s' never fires", expression)}
+	}
+	if len(runs) == 2 && runs[1].Sub(runs[0]) > 7*24*time.Hour {
+		return []preflight.Result{preflight.Warn(check, "check for renewals at least daily; certbot only renews when due",
+			"'%s' fires less than once a week", expression)}
+	}
+	return []preflight.Result{preflight.Pass(check, "'%s', next run at %s", expression, runs[0].Format(time.RFC3339))}
+}
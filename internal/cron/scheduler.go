@@ -2,24 +2,53 @@ package cron
 
 import (
 	"certbot-manager/internal/logging"
+	"certbot-manager/internal/metrics"
 	"fmt"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
+// parser accepts the same six-field expressions (with seconds) and descriptors as the scheduler.
+var parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// logger is the entry for the scheduler's own messages; cron's are logged with the same component.
+var logger = logging.Component("cron")
+
 // Scheduler wraps the cron instance.
 type Scheduler struct {
 	instance *cron.Cron
+	schedule cron.Schedule
 }
 
 // SetupAndStartScheduler initializes the cron scheduler, adds the specified job, and starts it.
 // It takes the cron expression string and the job function to execute.
 func SetupAndStartScheduler(expression string, job func()) (*Scheduler, error) {
+	scheduler, err := start("Renewal", expression, func(schedule cron.Schedule) {
+		metrics.ObserveScheduledRun()
+		metrics.SetNextRenewal(schedule.Next(time.Now()))
+		job()
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.SetNextRenewal(scheduler.schedule.Next(time.Now()))
+	return scheduler, nil
+}
+
+// StartJob schedules a job other than the renewal job on its own scheduler, e.g. the expiry watchdog. name
+// identifies the job in log messages.
+func StartJob(name, expression string, job func()) (*Scheduler, error) {
+	return start(name, expression, func(cron.Schedule) { job() })
+}
+
+// start creates a scheduler running job on the cron expression and starts it. job is passed the parsed schedule.
+func start(name, expression string, job func(schedule cron.Schedule)) (*Scheduler, error) {
 	if expression == "" {
 		return nil, fmt.Errorf("cron expression cannot be empty")
 	}
 
-	logrus.Info("--- Setting up Cron Scheduler ---")
+	logger.Infof("--- Setting up Cron Scheduler (%s) ---", name)
 
 	cronStdLogger := logging.NewLogrusStandardLogger(logrus.InfoLevel, "cron")
 	cronLogger := cron.PrintfLogger(cronStdLogger)
@@ -29,33 +58,71 @@ func SetupAndStartScheduler(expression string, job func()) (*Scheduler, error) {
 			cron.Recover(cronLogger),
 		),
 		cron.WithLogger(cronLogger),
-		cron.WithSeconds(),
+		cron.WithParser(parser),
 	)
 
-	logrus.Infof("Scheduling job with cron expression: %s", expression)
+	logger.Infof("Scheduling job with cron expression: %s", expression)
 
 	// Add the provided job function with the given expression
-	entryID, err := c.AddFunc(expression, job)
+	schedule, err := parser.Parse(expression)
 	if err != nil {
-		logrus.Errorf("Failed to add job to cron scheduler (expression: '%s'): %v", expression, err)
+		logger.Errorf("Failed to add job to cron scheduler (expression: '%s'): %v", expression, err)
 		return nil, fmt.Errorf("failed to add job to cron scheduler (expression: '%s'): %w", expression, err)
 	}
-	logrus.Infof("Renewal job added with ID: %d", entryID)
+	entryID := c.Schedule(schedule, cron.FuncJob(func() { job(schedule) }))
+	logger.Infof("%s job added with ID: %d", name, entryID)
 
 	c.Start()
-	logrus.Info("Cron scheduler started.")
+	logger.Info("Cron scheduler started.")
 
-	return &Scheduler{instance: c}, nil
+	return &Scheduler{instance: c, schedule: schedule}, nil
 }
 
 // Stop gracefully stops the cron scheduler, waiting for running jobs to complete.
 func (s *Scheduler) Stop() {
 	if s.instance != nil {
-		logrus.Info("Stopping cron scheduler gracefully...")
+		logger.Info("Stopping cron scheduler gracefully...")
 		ctx := s.instance.Stop()
 		<-ctx.Done()
-		logrus.Info("Cron scheduler stopped.")
+		logger.Info("Cron scheduler stopped.")
 	} else {
-		logrus.Warn("Scheduler instance is nil, cannot stop.")
+		logger.Warn("Scheduler instance is nil, cannot stop.")
+	}
+}
+
+// Next returns the next run of the scheduled job. It asks the scheduler's loop for it, so it returns an error if the
+// loop doesn't answer within timeout.
+func (s *Scheduler) Next(timeout time.Duration) (time.Time, error) {
+	reply := make(chan time.Time, 1)
+	go func() {
+		var next time.Time
+		for _, entry := range s.instance.Entries() {
+			next = entry.Next
+		}
+		reply <- next
+	}()
+	select {
+	case next := <-reply:
+		return next, nil
+	case <-time.After(timeout):
+		return time.Time{}, fmt.Errorf("scheduler loop didn't respond within %s", timeout)
+	}
+}
+
+// NextRuns parses a cron expression the way the scheduler does and returns its next n fire times after from.
+func NextRuns(expression string, from time.Time, n int) ([]time.Time, error) {
+	schedule, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expression, err)
+	}
+
+	runs := make([]time.Time, 0, n)
+	for next := from; len(runs) < n; {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break // The expression never fires again
+		}
+		runs = append(runs, next)
 	}
+	return runs, nil
 }
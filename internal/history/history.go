@@ -0,0 +1,209 @@
+// Package history persists a record of every certbot run in the state directory, so past runs can be inspected
+// with `certbot-manager history` long after their log lines are gone.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileName is the name of the history file inside the state directory. It holds one JSON record per line.
+const fileName = "history.jsonl"
+
+// What started a run, recorded as Record.Trigger.
+const (
+	TriggerStartup = "startup" // Initial processing when the manager starts
+	TriggerCron    = "cron"    // The renewal job
+	TriggerReload  = "reload"  // A configuration reload
+	TriggerManual  = "manual"  // A command such as `run --once`, `revoke` or `delete`
+)
+
+// stderrTailLines and stderrTailBytes bound the stderr kept in a record.
+const (
+	stderrTailLines = 20
+	stderrTailBytes = 4096
+)
+
+// Record is one certbot invocation for one certificate. A `certbot renew` run is recorded once per lineage.
+type Record struct {
+	RunID        string    `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	Cert         string    `json:"cert" yaml:"cert"` // Lineage or certificate name, empty if a renew run found none
+	Domains      []string  `json:"domains,omitempty" yaml:"domains,omitempty"`
+	Command      string    `json:"cmd" yaml:"cmd"`
+	Trigger      string    `json:"trigger" yaml:"trigger"`
+	Start        time.Time `json:"start" yaml:"start"`
+	End          time.Time `json:"end" yaml:"end"`
+	ExitCode     int       `json:"exit_code" yaml:"exit_code"`     // -1 if certbot couldn't be started or wasn't run
+	ErrorClass   string    `json:"error_class" yaml:"error_class"` // "success" or the class of the error
+	Error        string    `json:"error,omitempty" yaml:"error,omitempty"`
+	SerialBefore string    `json:"serial_before,omitempty" yaml:"serial_before,omitempty"`
+	SerialAfter  string    `json:"serial_after,omitempty" yaml:"serial_after,omitempty"`
+	StderrTail   string    `json:"stderr_tail,omitempty" yaml:"stderr_tail,omitempty"`
+}
+
+// Succeeded reports whether the run exited successfully.
+func (r Record) Succeeded() bool {
+	return r.Error == ""
+}
+
+// Issued reports whether the run left the lineage with a new certificate.
+func (r Record) Issued() bool {
+	return r.SerialAfter != "" && r.SerialAfter != r.SerialBefore
+}
+
+// Tail returns the last lines of stderr, bounded for storage in a record.
+func Tail(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+	tail := strings.Join(lines, "\n")
+	if len(tail) > stderrTailBytes {
+		tail = tail[len(tail)-stderrTailBytes:]
+	}
+	return tail
+}
+
+// Path returns the location of the history file in the state directory.
+func Path(dir string) string {
+	return filepath.Join(dir, fileName)
+}
+
+// Append adds records to the history file in dir, creating the directory if needed.
+func Append(dir string, records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := encode(records)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory '%s': %w", dir, err)
+	}
+	file, err := openLocked(Path(dir), os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return fmt.Errorf("failed to open history file '%s': %w", Path(dir), err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history file '%s': %w", Path(dir), err)
+	}
+	return file.Close()
+}
+
+// openLocked opens the history file at path and locks it exclusively until it is closed, so Append and Prune
+// don't interleave, even across processes. Prune replaces the file, so a file that was replaced while waiting for
+// the lock is reopened.
+func openLocked(path string, flag int) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(path, flag, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock: %w", err)
+		}
+		locked, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return file, nil
+		}
+		file.Close()
+	}
+}
+
+// Read returns the records in the history file in dir, oldest first. A missing file is an empty history; lines
+// that can't be parsed are skipped.
+func Read(dir string) ([]Record, error) {
+	file, err := os.Open(Path(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file '%s': %w", Path(dir), err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file '%s': %w", Path(dir), err)
+	}
+	return records, nil
+}
+
+// Prune removes the records that started before cutoff, rewriting the file atomically while it is locked against
+// Append. Lines that can't be parsed are kept as they are. It returns the number of records removed.
+func Prune(dir string, cutoff time.Time) (int, error) {
+	file, err := openLocked(Path(dir), os.O_RDONLY)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history file '%s': %w", Path(dir), err)
+	}
+	defer file.Close()
+
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil && record.Start.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read history file '%s': %w", Path(dir), err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp := Path(dir) + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to write history file '%s': %w", tmp, err)
+	}
+	if err := os.Rename(tmp, Path(dir)); err != nil {
+		return 0, fmt.Errorf("failed to replace history file '%s': %w", Path(dir), err)
+	}
+	return removed, nil
+}
+
+// encode renders records as JSON lines.
+func encode(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode history record: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
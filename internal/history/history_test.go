@@ -0,0 +1,104 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := `{"cert":"old","start":"2025-01-01T00:00:00Z"}`
+	recent := `{"cert":"recent","start":"2025-04-30T00:00:00Z"}`
+	tests := []struct {
+		name    string
+		lines   []string
+		removed int
+		want    []string
+	}{
+		{"nothing to prune", []string{recent}, 0, []string{recent}},
+		{"old records", []string{old, old, recent}, 2, []string{recent}},
+		{"unparseable lines are kept", []string{"not json", old, `{"cert":`, recent}, 1, []string{"not json", `{"cert":`, recent}},
+		{"every record", []string{old}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(Path(dir), []byte(strings.Join(tt.lines, "\n")+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			removed, err := Prune(dir, now.AddDate(0, 0, -30))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != tt.removed {
+				t.Errorf("removed %d records, want %d", removed, tt.removed)
+			}
+			data, err := os.ReadFile(Path(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(tt.want, "\n")
+			if len(tt.want) > 0 {
+				want += "\n"
+			}
+			if tt.removed == 0 {
+				want = strings.Join(tt.lines, "\n") + "\n"
+			}
+			if string(data) != want {
+				t.Errorf("history file =\n%s\nwant\n%s", data, want)
+			}
+		})
+	}
+}
+
+func TestPruneMissingFile(t *testing.T) {
+	if removed, err := Prune(t.TempDir(), time.Now()); removed != 0 || err != nil {
+		t.Errorf("Prune of a missing file = %d, %v", removed, err)
+	}
+}
+
+func TestAppendDuringPrune(t *testing.T) {
+	dir := t.TempDir()
+	old := Record{Cert: "old", Start: time.Now().AddDate(-1, 0, 0)}
+	for i := 0; i < 100; i++ {
+		if err := Append(dir, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := Append(dir, Record{Cert: fmt.Sprintf("cert%d", i), Start: time.Now()}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := Prune(dir, time.Now().AddDate(0, 0, -1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if record.Cert == "old" {
+			t.Fatal("an old record survived the prunes")
+		}
+	}
+	if len(records) != n {
+		t.Errorf("%d records after %d concurrent appends and prunes, want %d", len(records), n, n)
+	}
+}
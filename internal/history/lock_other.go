@@ -0,0 +1,10 @@
+//go:build !unix
+
+package history
+
+import "os"
+
+// lockFile does nothing: without flock, a prune may drop records appended while it runs.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,324 @@
+// Package importer generates certbot-manager configuration from an existing certbot configuration directory,
+// for hosts whose lineages were issued by hand.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"certbot-manager/internal/certbot/authenticators"
+	"certbot-manager/internal/certbot/flags"
+	"certbot-manager/internal/letsencrypt"
+)
+
+// DefaultRenewalCron is the renewal schedule written to imported configurations.
+const DefaultRenewalCron = "0 0 0,12 * * *"
+
+// Certbot's defaults for the DNS plugins' propagation delay, used when a renewal config doesn't set one.
+var defaultPropagationSeconds = map[string]int{
+	"dns-cloudflare": 10,
+	"dns-duckdns":    30,
+}
+
+// handledParams are the [renewalparams] keys the import maps to settings, or that carry no configuration.
+var handledParams = map[string]bool{
+	"account":                            true,
+	"authenticator":                      true,
+	"server":                             true,
+	"key_type":                           true,
+	"webroot_path":                       true,
+	"rsa_key_size":                       true,
+	"elliptic_curve":                     true,
+	"must_staple":                        true,
+	"preferred_chain":                    true,
+	"pre_hook":                           true,
+	"post_hook":                          true,
+	"renew_hook":                         true,
+	"installer":                          true,
+	"dns_cloudflare_credentials":         true,
+	"dns_cloudflare_propagation_seconds": true,
+	"dns_duckdns_token":                  true,
+	"dns_duckdns_propagation_seconds":    true,
+	"manual_public_ip_logging_ok":        true,
+}
+
+// argParams map [renewalparams] keys that certbot-manager has no setting for to the certbot option passed in args.
+var argParams = []struct{ param, option string }{
+	{"preferred_chain", "--preferred-chain"},
+	{"pre_hook", "--pre-hook"},
+	{"post_hook", "--post-hook"},
+	{"renew_hook", "--deploy-hook"},
+}
+
+// settingOrder is the order settings are written in, in [globals] and in each [[certificate]].
+var settingOrder = []string{
+	"email", "authenticator", "webroot_path", "cloudflare_credentials_path", "duckdns_token",
+	"dns_propagation_seconds", "staging", "server", "key_type", "args",
+}
+
+// Certificate is a [[certificate]] block generated from a lineage.
+type Certificate struct {
+	Lineage  string
+	Domains  []string
+	Settings map[string]any // Config key to string, bool, int or []string (args)
+	Comments []string       // Settings that couldn't be mapped, written as comments
+}
+
+// Result is a generated configuration.
+type Result struct {
+	ConfigDir    string
+	Globals      map[string]any
+	Comments     []string // Written as comments in [globals]
+	Certificates []Certificate
+}
+
+// Import reads every lineage in configDir and maps its renewal config and live certificate to a
+// [[certificate]] block. Values shared by every certificate are moved to [globals].
+func Import(configDir string) (*Result, error) {
+	lineages, err := letsencrypt.Lineages(configDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(lineages) == 0 {
+		return nil, fmt.Errorf("no renewal configs found in '%s'", filepath.Join(configDir, "renewal"))
+	}
+
+	result := &Result{ConfigDir: configDir, Globals: map[string]any{}}
+	for _, lineage := range lineages {
+		if lineage.Disabled {
+			result.Comments = append(result.Comments, fmt.Sprintf("lineage '%s' was skipped: its renewal is stopped", lineage.Name))
+			continue
+		}
+		if lineage.Err != nil {
+			result.Comments = append(result.Comments, fmt.Sprintf("lineage '%s' was skipped: %v", lineage.Name, lineage.Err))
+			continue
+		}
+		result.Certificates = append(result.Certificates, importLineage(configDir, lineage))
+	}
+	result.deduplicate()
+
+	if _, ok := result.Globals["email"]; !ok {
+		result.Comments = append(result.Comments, "no account email found; set 'email' in [globals]")
+	}
+	return result, nil
+}
+
+// importLineage maps a single lineage.
+func importLineage(configDir string, lineage letsencrypt.Lineage) Certificate {
+	params := lineage.Renewal.Params
+	cert := Certificate{Lineage: lineage.Name, Settings: map[string]any{}}
+	unmapped := func(format string, args ...any) {
+		cert.Comments = append(cert.Comments, fmt.Sprintf(format, args...))
+	}
+
+	cert.Domains = lineageDomains(lineage)
+	if len(cert.Domains) == 0 {
+		unmapped("no domains found: the certificate could not be read (%v) and there is no webroot_map", lineage.CertErr)
+	}
+
+	if email := accountEmail(configDir, params["account"]); email != "" {
+		cert.Settings["email"] = email
+	}
+
+	authenticator := params["authenticator"]
+	if slices.Contains(authenticators.Names(), authenticator) {
+		cert.Settings["authenticator"] = authenticator
+	} else {
+		unmapped("authenticator = %q is not supported by certbot-manager (options: %v)", authenticator, authenticators.Names())
+	}
+	switch authenticator {
+	case "webroot":
+		importWebroot(lineage, cert.Domains, &cert, unmapped)
+	case "dns-cloudflare":
+		if path := params["dns_cloudflare_credentials"]; path != "" {
+			cert.Settings["cloudflare_credentials_path"] = path
+		}
+		cert.Settings["dns_propagation_seconds"] = propagationSeconds(params["dns_cloudflare_propagation_seconds"], authenticator)
+	case "dns-duckdns":
+		// The token is a secret: it is read from the environment instead of being copied into the file, and loading the
+		// configuration fails with a clear message while it is unset.
+		cert.Settings["duckdns_token"] = "${DUCKDNS_TOKEN:?set DUCKDNS_TOKEN to the DuckDNS token}"
+		cert.Settings["dns_propagation_seconds"] = propagationSeconds(params["dns_duckdns_propagation_seconds"], authenticator)
+	}
+	if installer := params["installer"]; installer != "" && !strings.EqualFold(installer, "none") {
+		unmapped("installer = %q is not supported by certbot-manager", installer)
+	}
+
+	switch server := lineage.Renewal.Server(); {
+	case server == "" || server == letsencrypt.ProductionServer:
+		cert.Settings["staging"] = false
+	case server == letsencrypt.StagingServer:
+		cert.Settings["staging"] = true
+	default:
+		cert.Settings["server"] = server
+	}
+
+	keyType := params["key_type"]
+	if keyType == "" && lineage.Cert != nil {
+		keyType, _ = letsencrypt.KeyType(lineage.Cert)
+	}
+	if slices.Contains(flags.KeyTypes, keyType) {
+		cert.Settings["key_type"] = keyType
+	} else if keyType != "" {
+		unmapped("key_type = %q is not supported by certbot-manager (options: %v)", keyType, flags.KeyTypes)
+	}
+
+	var args []string
+	if size := params["rsa_key_size"]; size != "" && size != "2048" && keyType == "rsa" {
+		args = append(args, "--rsa-key-size", size)
+	}
+	if curve := params["elliptic_curve"]; curve != "" && curve != "secp256r1" && keyType == "ecdsa" {
+		args = append(args, "--elliptic-curve", curve)
+	}
+	if isTrue(params["must_staple"]) {
+		args = append(args, "--must-staple")
+	}
+	for _, p := range argParams {
+		if value := params[p.param]; value != "" {
+			args = append(args, p.option, value)
+		}
+	}
+	if len(args) > 0 {
+		cert.Settings["args"] = args
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !handledParams[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		unmapped("unmapped renewal parameter: %s = %s", key, params[key])
+	}
+	return cert
+}
+
+// lineageDomains returns the lineage's domains, with the lineage name first: certbot names a new lineage after
+// the first domain, so the manager keeps using the existing one.
+func lineageDomains(lineage letsencrypt.Lineage) []string {
+	domains := append([]string{}, lineage.Domains()...)
+	if len(domains) == 0 {
+		for domain := range lineage.Renewal.WebrootMap {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+	}
+	for i, domain := range domains {
+		if strings.EqualFold(domain, lineage.Name) {
+			domains = append([]string{domain}, append(domains[:i:i], domains[i+1:]...)...)
+			break
+		}
+	}
+	return domains
+}
+
+// importWebroot maps the webroot map to webroot_path. certbot-manager uses a single webroot per certificate,
+// so differing per-domain paths are reported.
+func importWebroot(lineage letsencrypt.Lineage, domains []string, cert *Certificate, unmapped func(string, ...any)) {
+	webrootMap := lineage.Renewal.WebrootMap
+	paths := map[string]bool{}
+	var path string
+	for _, domain := range domains {
+		if p, ok := webrootMap[domain]; ok {
+			if path == "" {
+				path = p
+			}
+			paths[p] = true
+		}
+	}
+	if path == "" {
+		if list := letsencrypt.List(lineage.Renewal.Params["webroot_path"]); len(list) > 0 {
+			path = list[0]
+		}
+	}
+	if path == "" {
+		unmapped("no webroot path found; set webroot_path")
+		return
+	}
+	cert.Settings["webroot_path"] = path
+	if len(paths) > 1 {
+		var entries []string
+		for _, domain := range domains {
+			entries = append(entries, fmt.Sprintf("%s = %s", domain, webrootMap[domain]))
+		}
+		unmapped("webroot_map uses different paths per domain (%s); only %s is used, split the certificate if needed",
+			strings.Join(entries, ", "), path)
+	}
+}
+
+// deduplicate moves the settings every certificate has with the same value to [globals].
+func (r *Result) deduplicate() {
+	if len(r.Certificates) < 2 {
+		return
+	}
+	for _, key := range settingOrder {
+		first, ok := r.Certificates[0].Settings[key]
+		if !ok {
+			continue
+		}
+		shared := true
+		for _, cert := range r.Certificates[1:] {
+			value, ok := cert.Settings[key]
+			if !ok || fmt.Sprint(value) != fmt.Sprint(first) {
+				shared = false
+				break
+			}
+		}
+		if !shared {
+			continue
+		}
+		r.Globals[key] = first
+		for _, cert := range r.Certificates {
+			delete(cert.Settings, key)
+		}
+	}
+}
+
+// accountEmail returns the contact email of the ACME account with the given id, if it can be found.
+func accountEmail(configDir, account string) string {
+	if account == "" {
+		return ""
+	}
+	matches, _ := filepath.Glob(filepath.Join(configDir, "accounts", "*", "*", account, "regr.json"))
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var regr struct {
+			Body struct {
+				Contact []string `json:"contact"`
+			} `json:"body"`
+		}
+		if json.Unmarshal(data, &regr) != nil {
+			continue
+		}
+		for _, contact := range regr.Body.Contact {
+			if email, ok := strings.CutPrefix(contact, "mailto:"); ok {
+				return email
+			}
+		}
+	}
+	return ""
+}
+
+// propagationSeconds parses a plugin's propagation delay, falling back to certbot's default for the plugin.
+func propagationSeconds(value, authenticator string) int {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds
+	}
+	return defaultPropagationSeconds[authenticator]
+}
+
+// isTrue reports whether a ConfigObj value is true.
+func isTrue(value string) bool {
+	return strings.EqualFold(value, "true")
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/tomledit"
+)
+
+// WriteTOML writes the generated configuration as a config.toml file.
+func (r *Result) WriteTOML(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by `certbot-manager import --from %s`.\n", r.ConfigDir)
+	b.WriteString("# Review the comments below before using this configuration.\n\n")
+
+	b.WriteString("[globals]\n")
+	fmt.Fprintf(&b, "renewal_cron = %s\n", tomledit.Value(DefaultRenewalCron))
+	if r.ConfigDir != letsencrypt.DefaultConfigDir {
+		fmt.Fprintf(&b, "config_dir = %s\n", tomledit.Value(r.ConfigDir))
+	}
+	writeSettings(&b, r.Globals)
+	writeComments(&b, r.Comments)
+
+	for _, cert := range r.Certificates {
+		fmt.Fprintf(&b, "\n# Lineage: %s\n", cert.Lineage)
+		b.WriteString("[[certificate]]\n")
+		fmt.Fprintf(&b, "domains = %s\n", tomledit.Value(cert.Domains))
+		writeSettings(&b, cert.Settings)
+		writeComments(&b, cert.Comments)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSettings writes settings as key/value pairs in settingOrder.
+func writeSettings(b *strings.Builder, settings map[string]any) {
+	for _, key := range settingOrder {
+		if value, ok := settings[key]; ok {
+			fmt.Fprintf(b, "%s = %s", key, tomledit.Value(value))
+			if key == "duckdns_token" {
+				b.WriteString(" # Read from the environment, see Environment Variable Interpolation")
+			}
+			b.WriteString("\n")
+		}
+	}
+}
+
+// writeComments writes each message as a comment line.
+func writeComments(b *strings.Builder, comments []string) {
+	for _, comment := range comments {
+		fmt.Fprintf(b, "# TODO: %s\n", strings.ReplaceAll(comment, "\n", " "))
+	}
+}
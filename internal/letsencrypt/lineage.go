@@ -0,0 +1,255 @@
+package letsencrypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigDir is certbot's default configuration directory.
+const DefaultConfigDir = "/etc/letsencrypt"
+
+// disabledSuffix is appended to renewal configs whose renewal was stopped, so `certbot renew` skips them.
+const disabledSuffix = ".disabled"
+
+// Lineage is a certificate lineage found in a certbot configuration directory.
+type Lineage struct {
+	Name        string
+	RenewalPath string // renewal/<name>.conf, or renewal/<name>.conf.disabled when renewal is stopped
+	Renewal     *RenewalConf
+	CertPath    string
+	Cert        *x509.Certificate // nil if the certificate could not be read
+	CertErr     error             // Why Cert is nil
+	Disabled    bool
+	Err         error // Why the renewal config could not be read; Renewal is empty then
+}
+
+// Domains returns the SANs of the lineage certificate, or nil if it could not be read.
+func (l Lineage) Domains() []string {
+	if l.Cert == nil {
+		return nil
+	}
+	return l.Cert.DNSNames
+}
+
+// Serial returns the serial number of the lineage certificate in hex, or "" if it could not be read.
+func (l Lineage) Serial() string {
+	if l.Cert == nil {
+		return ""
+	}
+	return l.Cert.SerialNumber.Text(16)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the lineage certificate in hex, or "" if it could not be read.
+func (l Lineage) Fingerprint() string {
+	if l.Cert == nil {
+		return ""
+	}
+	sum := sha256.Sum256(l.Cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Lineages lists every lineage with a renewal config in configDir, sorted by name.
+// Lineages whose renewal was stopped by StopRenewal are included with Disabled set. A lineage whose renewal config
+// can't be read is included with Err set, so one broken lineage doesn't hide the others.
+func Lineages(configDir string) ([]Lineage, error) {
+	renewalDir := filepath.Join(configDir, "renewal")
+	entries, err := os.ReadDir(renewalDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list renewal configs in '%s': %w", renewalDir, err)
+	}
+
+	var lineages []Lineage
+	for _, entry := range entries {
+		name, disabled := lineageName(entry.Name())
+		if entry.IsDir() || name == "" {
+			continue
+		}
+		lineage := readLineage(configDir, name, filepath.Join(renewalDir, entry.Name()))
+		lineage.Disabled = disabled
+		lineages = append(lineages, lineage)
+	}
+
+	sort.Slice(lineages, func(i, j int) bool { return lineages[i].Name < lineages[j].Name })
+	return lineages, nil
+}
+
+// FindLineage returns the lineage with the given name, or nil if there is none.
+func FindLineage(configDir, name string) (*Lineage, error) {
+	lineages, err := Lineages(configDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lineages {
+		if lineages[i].Name == name {
+			return &lineages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// StopRenewal renames the lineage's renewal config so `certbot renew` no longer picks it up.
+func StopRenewal(lineage Lineage) error {
+	if lineage.Disabled {
+		return nil
+	}
+	return os.Rename(lineage.RenewalPath, lineage.RenewalPath+disabledSuffix)
+}
+
+// ResumeRenewal undoes StopRenewal.
+func ResumeRenewal(lineage Lineage) error {
+	if !lineage.Disabled {
+		return nil
+	}
+	return os.Rename(lineage.RenewalPath, strings.TrimSuffix(lineage.RenewalPath, disabledSuffix))
+}
+
+// Serials maps the name of every lineage in configDir to the serial number of its certificate, in hex.
+// Lineages whose certificate can't be read are left out. Comparing two snapshots tells which lineages were issued
+// or renewed in between.
+func Serials(configDir string) (map[string]string, error) {
+	lineages, err := Lineages(configDir)
+	if err != nil {
+		return nil, err
+	}
+	serials := make(map[string]string, len(lineages))
+	for _, lineage := range lineages {
+		if serial := lineage.Serial(); serial != "" {
+			serials[lineage.Name] = serial
+		}
+	}
+	return serials, nil
+}
+
+// SameDomains reports whether two domain lists cover the same set of names, ignoring order and case.
+func SameDomains(a, b []string) bool {
+	return strings.Join(normalizeDomains(a), ",") == strings.Join(normalizeDomains(b), ",")
+}
+
+// ReadCertificate parses the first PEM certificate in path.
+func ReadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate '%s': %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found in '%s'", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate '%s': %w", path, err)
+	}
+	return cert, nil
+}
+
+// IsStagingIssuer reports whether cert was issued by a Let's Encrypt staging CA. Their issuer names are
+// prefixed with "(STAGING)", or "Fake LE" for the older ones.
+func IsStagingIssuer(cert *x509.Certificate) bool {
+	issuer := cert.Issuer.String()
+	return strings.Contains(issuer, "(STAGING)") || strings.Contains(issuer, "Fake LE")
+}
+
+// KeyType returns the certbot --key-type name of the certificate key and its size or curve,
+// e.g. ("rsa", "2048") or ("ecdsa", "P-256").
+func KeyType(cert *x509.Certificate) (string, string) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "rsa", strconv.Itoa(key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ecdsa", key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "ed25519", ""
+	default:
+		return strings.ToLower(cert.PublicKeyAlgorithm.String()), ""
+	}
+}
+
+// MatchLineage returns the lineage that was most likely issued for the given domains, or nil if none shares a
+// domain with them. A lineage covering exactly the domains wins, then one named after the first domain (certbot's
+// default lineage name), then the one sharing the most domains.
+func MatchLineage(lineages []Lineage, domains []string) *Lineage {
+	if len(domains) == 0 {
+		return nil
+	}
+	wanted := make(map[string]bool, len(domains))
+	for _, domain := range normalizeDomains(domains) {
+		wanted[domain] = true
+	}
+
+	var best *Lineage
+	bestShared := 0
+	for i := range lineages {
+		lineage := &lineages[i]
+		if SameDomains(lineage.Domains(), domains) {
+			return lineage
+		}
+		if strings.EqualFold(lineage.Name, strings.TrimSpace(domains[0])) {
+			best, bestShared = lineage, len(domains)+1
+			continue
+		}
+		shared := 0
+		for _, domain := range normalizeDomains(lineage.Domains()) {
+			if wanted[domain] {
+				shared++
+			}
+		}
+		if shared > bestShared {
+			best, bestShared = lineage, shared
+		}
+	}
+	return best
+}
+
+// readLineage loads the renewal config and certificate of a lineage. If the renewal config can't be read, the
+// certificate is read from its default location.
+func readLineage(configDir, name, renewalPath string) Lineage {
+	renewal, err := ReadRenewalConf(renewalPath)
+	if err != nil {
+		renewal = &RenewalConf{}
+	}
+
+	certPath := renewal.Values["cert"]
+	if certPath == "" {
+		certPath = filepath.Join(configDir, "live", name, "cert.pem")
+	}
+
+	lineage := Lineage{Name: name, RenewalPath: renewalPath, Renewal: renewal, CertPath: certPath, Err: err}
+	lineage.Cert, lineage.CertErr = ReadCertificate(certPath)
+	return lineage
+}
+
+// lineageName extracts the lineage name from a renewal config file name.
+func lineageName(fileName string) (string, bool) {
+	if name, ok := strings.CutSuffix(fileName, ".conf"+disabledSuffix); ok {
+		return name, true
+	}
+	if name, ok := strings.CutSuffix(fileName, ".conf"); ok {
+		return name, false
+	}
+	return "", false
+}
+
+// normalizeDomains lowercases and sorts a domain list.
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, len(domains))
+	for i, domain := range domains {
+		normalized[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,63 @@
+package letsencrypt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineagesKeepsGoingPastBrokenOnes(t *testing.T) {
+	dir := t.TempDir()
+	renewal := filepath.Join(dir, "renewal")
+	if err := os.MkdirAll(renewal, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"good.conf":             "version = 2.0\n[renewalparams]\nserver = https://ca.example/dir\n",
+		"stopped.conf.disabled": "version = 2.0\n",
+		"hand-edited.conf":      "version = 2.0\nnot a key value line\n",
+		"README":                "not a renewal config\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(renewal, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(renewal, "dangling.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	lineages, err := Lineages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		disabled bool
+		broken   bool
+	}{
+		{"dangling", false, true},
+		{"good", false, false},
+		{"hand-edited", false, true},
+		{"stopped", true, false},
+	}
+	if len(lineages) != len(tests) {
+		t.Fatalf("Lineages found %d lineages, want %d", len(lineages), len(tests))
+	}
+	for i, tt := range tests {
+		lineage := lineages[i]
+		if lineage.Name != tt.name || lineage.Disabled != tt.disabled || (lineage.Err != nil) != tt.broken {
+			t.Errorf("lineage %d = %s (disabled %v, err %v), want %s (disabled %v, broken %v)",
+				i, lineage.Name, lineage.Disabled, lineage.Err, tt.name, tt.disabled, tt.broken)
+		}
+		if lineage.Renewal == nil {
+			t.Errorf("lineage %s has no renewal config", lineage.Name)
+		}
+		if want := filepath.Join(dir, "live", tt.name, "cert.pem"); lineage.CertPath != want {
+			t.Errorf("lineage %s cert path = %s, want %s", lineage.Name, lineage.CertPath, want)
+		}
+	}
+	if server := lineages[1].Renewal.Server(); server != "https://ca.example/dir" {
+		t.Errorf("good lineage server = %q", server)
+	}
+}
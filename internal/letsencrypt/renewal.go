@@ -0,0 +1,93 @@
+package letsencrypt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ACME directory URLs of the Let's Encrypt environments.
+const (
+	ProductionServer = "https://acme-v02.api.letsencrypt.org/directory"
+	StagingServer    = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// RenewalConf is a parsed certbot renewal configuration (renewal/<name>.conf).
+// The file uses the ConfigObj format: top-level keys, a [renewalparams] section and an optional
+// [[webroot_map]] subsection.
+type RenewalConf struct {
+	Values     map[string]string // Top-level keys (version, archive_dir, cert, privkey, chain, fullchain)
+	Params     map[string]string // Keys of the [renewalparams] section
+	WebrootMap map[string]string // Domain to webroot path, from [[webroot_map]]
+}
+
+// ReadRenewalConf parses the renewal configuration at path.
+func ReadRenewalConf(path string) (*RenewalConf, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open renewal config '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	conf := &RenewalConf{
+		Values:     map[string]string{},
+		Params:     map[string]string{},
+		WebrootMap: map[string]string{},
+	}
+	section := conf.Values
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "[renewalparams]":
+			section = conf.Params
+		case line == "[[webroot_map]]":
+			section = conf.WebrootMap
+		case strings.HasPrefix(line, "["):
+			// Sections certbot-manager doesn't use (e.g. plugin specific ones) are skipped.
+			section = nil
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid line %d in renewal config '%s': %q", lineNo, path, line)
+			}
+			if section != nil {
+				section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read renewal config '%s': %w", path, err)
+	}
+	return conf, nil
+}
+
+// Server returns the ACME directory URL the lineage was issued from, empty if unknown.
+func (c *RenewalConf) Server() string {
+	return c.Params["server"]
+}
+
+// IsStaging reports whether the lineage was issued by a staging ACME server.
+func (c *RenewalConf) IsStaging() bool {
+	return IsStagingServer(c.Server())
+}
+
+// IsStagingServer reports whether an ACME directory URL points at a staging environment.
+func IsStagingServer(server string) bool {
+	return strings.Contains(server, "staging")
+}
+
+// List splits a ConfigObj list value ("a, b," or "a") into its items.
+func List(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journalSocket is where journald receives entries in its native protocol.
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journald is a sink sending entries to the systemd journal, with the fields as journal fields.
+type Journald struct {
+	// Send entries to the journal
+	Enabled bool `mapstructure:"enabled"`
+	// Level of the sink, defaults to --log-level
+	Level string `mapstructure:"level"`
+	// SYSLOG_IDENTIFIER of the entries, defaults to certbot-manager
+	Identifier string `mapstructure:"identifier"`
+}
+
+// Validate checks the level.
+func (j Journald) Validate() error {
+	if j.Level == "" {
+		return nil
+	}
+	if _, err := logrus.ParseLevel(j.Level); err != nil {
+		return fmt.Errorf("logging.journald: %w", err)
+	}
+	return nil
+}
+
+// journalFields names the journal fields of the context fields whose upper-cased name isn't explicit enough.
+// Other fields are upper-cased, e.g. run_id becomes RUN_ID.
+var journalFields = map[string]string{
+	FieldCert: "CERT_NAME",
+}
+
+// journalSink returns the sink sending to journald, at level unless cfg sets its own.
+func journalSink(cfg Journald, level logrus.Level) (*sink, *journalWriter, error) {
+	level, _ = parseLevel(cfg.Level, level)
+	formatter := &journalFormatter{identifier: cfg.Identifier}
+	if formatter.identifier == "" {
+		formatter.identifier = defaultAppName
+	}
+	w := &journalWriter{}
+	if err := w.connect(); err != nil {
+		return nil, nil, err
+	}
+	return &sink{w: w, level: level, inherit: cfg.Level == "", formatter: formatter}, w, nil
+}
+
+// journalFormatter formats entries in journald's native protocol: one field per line, with values that contain
+// newlines prefixed by their length instead.
+type journalFormatter struct {
+	identifier string
+}
+
+func (f *journalFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", entry.Message)
+	writeJournalField(&b, "PRIORITY", fmt.Sprint(syslogSeverity(entry.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", f.identifier)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := journalFieldName(key)
+		// A field may repeat, so `journalctl DOMAINS=www.example.com` matches any of the certificate's domains.
+		if values, ok := entry.Data[key].([]string); ok {
+			for _, value := range values {
+				writeJournalField(&b, name, value)
+			}
+			continue
+		}
+		writeJournalField(&b, name, fieldString(entry.Data[key]))
+	}
+	return b.Bytes(), nil
+}
+
+// writeJournalField appends one field to b.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName returns the journal field of a context field. Journal field names are upper-case letters,
+// digits and underscores, not starting with an underscore or a digit, which journald reserves or rejects.
+func journalFieldName(key string) string {
+	if name, ok := journalFields[key]; ok {
+		return name
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// journalWriter sends each Write as one datagram to journald, reconnecting once if journald was restarted.
+type journalWriter struct {
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+func (w *journalWriter) connect() error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to journald at '%s': %w", journalSocket, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *journalWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		_, err := w.conn.Write(p)
+		if err == nil {
+			return len(p), nil
+		}
+		// Entries too large for a datagram are passed as a file instead.
+		if err = sendLargeJournalEntry(w.conn, p, err); err == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	if _, err := w.conn.Write(p); err != nil {
+		if err = sendLargeJournalEntry(w.conn, p, err); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *journalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendLargeJournalEntry passes an entry that failed to send with err because it exceeds the datagram size limit as
+// a file descriptor of an unlinked temporary file, like sd_journal_send does. Other errors are returned as is.
+func sendLargeJournalEntry(conn *net.UnixConn, p []byte, err error) error {
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+	file, err := os.CreateTemp("/dev/shm", "certbot-manager-journal-")
+	if err != nil {
+		return fmt.Errorf("failed to create a file for a large journal entry: %w", err)
+	}
+	defer file.Close()
+	_ = os.Remove(file.Name())
+	if _, err := file.Write(p); err != nil {
+		return fmt.Errorf("failed to write a large journal entry: %w", err)
+	}
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), nil)
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package logging
+
+import "net"
+
+// sendLargeJournalEntry returns err: journald, and passing entries as files, only exist on Linux.
+func sendLargeJournalEntry(_ *net.UnixConn, _ []byte, err error) error {
+	return err
+}
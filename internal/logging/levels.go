@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Override sets the level of the entries of one certificate or component, on top of the sinks' levels: entries it
+// matches are written by every sink down to its level, e.g. debug for the certificate of a flaky DNS plugin.
+type Override struct {
+	// Certificate name or one of its domains
+	Cert string `mapstructure:"cert" json:"cert,omitempty"`
+	// Component, e.g. cron, runner, reconciler or watchdog
+	Component string `mapstructure:"component" json:"component,omitempty"`
+	Level     string `mapstructure:"level" json:"level" jsonschema:"required"`
+}
+
+// Validate checks that o selects a certificate or component and sets a valid level.
+func (o Override) Validate() error {
+	if o.Cert == "" && o.Component == "" {
+		return fmt.Errorf("cert or component is required")
+	}
+	if _, err := logrus.ParseLevel(o.Level); err != nil {
+		return err
+	}
+	return nil
+}
+
+// matches reports whether entry is about o's certificate and component.
+func (o Override) matches(entry *logrus.Entry) bool {
+	if o.Component != "" && entry.Data[FieldComponent] != o.Component {
+		return false
+	}
+	if o.Cert == "" {
+		return true
+	}
+	if cert, ok := entry.Data[FieldCert].(string); ok && strings.EqualFold(cert, o.Cert) {
+		return true
+	}
+	domains, _ := entry.Data[FieldDomains].([]string)
+	for _, domain := range domains {
+		if strings.EqualFold(domain, o.Cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// levelState is the level of the sinks without their own level, and the overrides in effect.
+type levelState struct {
+	base      logrus.Level
+	overrides []Override
+	parsed    []logrus.Level // Levels of overrides
+}
+
+// raises reports whether an override lets entry through below the sinks' levels.
+func (s *levelState) raises(entry *logrus.Entry) bool {
+	for i, o := range s.overrides {
+		if entry.Level <= s.parsed[i] && o.matches(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// newLevelState returns the state for base and overrides, which must be valid.
+func newLevelState(base logrus.Level, overrides []Override) *levelState {
+	s := &levelState{base: base, overrides: overrides, parsed: make([]logrus.Level, len(overrides))}
+	for i, o := range overrides {
+		s.parsed[i], _ = logrus.ParseLevel(o.Level)
+	}
+	return s
+}
+
+// active is read by the sinks on every entry; it is replaced, never modified.
+var active atomic.Pointer[levelState]
+
+// levels serializes the changes of active and holds the state set by the last Configure, which ResetLevels
+// returns to.
+var levels struct {
+	sync.Mutex
+	configured *levelState
+}
+
+func init() {
+	active.Store(newLevelState(logrus.InfoLevel, nil))
+}
+
+// LevelState describes the levels in effect, as reported by the admin endpoint.
+type LevelState struct {
+	Level           string     `json:"level"`            // Level of the sinks without their own level
+	ConfiguredLevel string     `json:"configured_level"` // --log-level or globals.log_level
+	Overrides       []Override `json:"overrides"`
+}
+
+// CurrentLevels returns the levels in effect.
+func CurrentLevels() LevelState {
+	levels.Lock()
+	defer levels.Unlock()
+	s := active.Load()
+	state := LevelState{Level: s.base.String(), ConfiguredLevel: s.base.String(), Overrides: append([]Override{}, s.overrides...)}
+	if levels.configured != nil {
+		state.ConfiguredLevel = levels.configured.base.String()
+	}
+	return state
+}
+
+// SetLevel changes the level of the sinks without their own level until the next Configure.
+func SetLevel(name string) error {
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	levels.Lock()
+	defer levels.Unlock()
+	apply(newLevelState(level, active.Load().overrides))
+	return nil
+}
+
+// ToggleDebug switches the sinks without their own level to debug, or back to their configured level if they
+// already log debug entries. It returns the new level.
+func ToggleDebug() logrus.Level {
+	levels.Lock()
+	defer levels.Unlock()
+	base := logrus.InfoLevel
+	if levels.configured != nil {
+		base = levels.configured.base
+	}
+
+	s := active.Load()
+	level := logrus.DebugLevel
+	if s.base >= logrus.DebugLevel {
+		level = base
+		if base >= logrus.DebugLevel {
+			level = logrus.InfoLevel
+		}
+	}
+	apply(newLevelState(level, s.overrides))
+	return level
+}
+
+// SetOverrides replaces the overrides in effect until the next Configure.
+func SetOverrides(overrides []Override) error {
+	for i, o := range overrides {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("override #%d: %w", i+1, err)
+		}
+	}
+	levels.Lock()
+	defer levels.Unlock()
+	apply(newLevelState(active.Load().base, overrides))
+	return nil
+}
+
+// ResetLevels returns to the level and overrides set by the last Configure.
+func ResetLevels() {
+	levels.Lock()
+	defer levels.Unlock()
+	if levels.configured != nil {
+		apply(levels.configured)
+	}
+}
+
+// apply puts s in effect, with levels locked, and sets the logger's level to the most verbose level a sink or
+// override can write.
+func apply(s *levelState) {
+	active.Store(s)
+	outputs.Lock()
+	sinks := outputs.sinks
+	outputs.Unlock()
+	logrus.SetLevel(loggerLevel(sinks, s))
+}
+
+// loggerLevel returns the most verbose level of sinks and the overrides of s, below which entries aren't created.
+func loggerLevel(sinks []*sink, s *levelState) logrus.Level {
+	level := logrus.PanicLevel
+	for _, sink := range sinks {
+		level = max(level, sink.threshold(s))
+	}
+	for _, parsed := range s.parsed {
+		level = max(level, parsed)
+	}
+	return level
+}
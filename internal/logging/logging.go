@@ -1,33 +1,126 @@
 package logging
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
-	"github.com/t-tomalak/logrus-easy-formatter"
 )
 
-// Setup initializes the global logrus logger with the specified level.
-func Setup(levelStr string) error {
-	logrus.SetFormatter(&easy.Formatter{
-		TimestampFormat: "2006-01-02 15:04:05.00",
-		LogFormat:       "%time% [%lvl%] %msg%\n",
-	})
+// Values accepted by --log-format and the `log_format` setting.
+const (
+	FormatText   = "text"   // Human-readable lines, context fields appended as key=value
+	FormatJSON   = "json"   // One JSON object per line, for Loki, Elastic and the like
+	FormatLogfmt = "logfmt" // time=... level=... msg=... key=value
+)
 
-	logrus.SetOutput(os.Stderr)
+// Formats lists the values accepted by --log-format.
+var Formats = []string{FormatText, FormatJSON, FormatLogfmt}
 
-	// Parse the level string
-	level, err := logrus.ParseLevel(strings.ToLower(levelStr))
-	if err != nil {
-		log.Printf("Warning: Invalid log level '%s' provided: %v. Defaulting to 'info'.", levelStr, err)
-		level = logrus.InfoLevel
+// Levels lists the names of the log levels, most severe first.
+func Levels() []string {
+	names := make([]string, len(logrus.AllLevels))
+	for i, level := range logrus.AllLevels {
+		names[i] = level.String()
 	}
+	return names
+}
+
+// Context fields attached to log entries. Every package uses these names, so structured logs can be filtered by
+// them consistently.
+const (
+	FieldComponent     = "component"      // Part of the manager: runner, cron, config, ...
+	FieldRunID         = "run_id"         // Correlates the entries of one certificate processing or renewal run
+	FieldCert          = "cert"           // Certificate name, its first domain like certbot's lineage name
+	FieldDomains       = "domains"        // Domains of the certificate
+	FieldAuthenticator = "authenticator"  // Authenticator of the certificate's challenge
+	FieldAttempt       = "attempt"        // 1 + consecutive failed runs of the certificate
+	FieldTrigger       = "trigger"        // What started the run: startup, cron, reload or manual
+	FieldTraceID       = "trace_id"       // OpenTelemetry trace of the run, when tracing is on
+	FieldSpanID        = "span_id"        // OpenTelemetry span the entry was logged in
+	FieldPath          = "path"           // Certificate file checked by the expiry watchdog
+	FieldSeverity      = "severity"       // Expiry severity: ok, warning, critical, expired or unreadable
+	FieldDaysRemaining = "days_remaining" // Whole days until the certificate expires
+)
 
-	logrus.SetLevel(level)
+const (
+	timestampFormat = "2006-01-02 15:04:05.00"
+	// structuredTimestampFormat is RFC 3339 with milliseconds, for the json and logfmt formats.
+	structuredTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Setup initializes the global logrus logger to write to stderr with the specified level and format.
+func Setup(levelStr, format string) error {
+	return Configure(levelStr, format, Config{})
+}
 
-	return nil
+// newFormatter returns the formatter for one of Formats. An empty format is FormatText.
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case FormatText, "":
+		return &textFormatter{}, nil
+	case FormatJSON:
+		return &logrus.JSONFormatter{TimestampFormat: structuredTimestampFormat}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true, TimestampFormat: structuredTimestampFormat}, nil
+	}
+	return nil, fmt.Errorf("unknown log format '%s' (options: %v)", format, Formats)
+}
+
+// Component returns an entry for the log messages of a part of the manager.
+func Component(name string) *logrus.Entry {
+	return logrus.WithField(FieldComponent, name)
+}
+
+// NewRunID returns a short random ID for FieldRunID.
+func NewRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000"
+	}
+	return hex.EncodeToString(b)
+}
+
+// textFormatter writes `<time> [<LEVEL>] <message>` followed by the entry's fields as sorted key=value pairs.
+type textFormatter struct{}
+
+func (f *textFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s [%s] %s", entry.Time.Format(timestampFormat), strings.ToUpper(entry.Level.String()), entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, textValue(entry.Data[key]))
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// textValue renders a field value, quoting it if it contains spaces, quotes or '='.
+func textValue(value any) string {
+	s := fieldString(value)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// fieldString renders a field value, lists as comma-separated values.
+func fieldString(value any) string {
+	if values, ok := value.([]string); ok {
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(value)
 }
 
 // logrusWriter adapts logrus entry to io.Writer for standard logger
@@ -64,7 +157,7 @@ func (w *logrusWriter) Write(p []byte) (n int, err error) {
 // NewLogrusStandardLogger creates a standard library logger that writes to logrus
 // at the specified level. Prefix is handled by logrus, flags usually 0.
 func NewLogrusStandardLogger(level logrus.Level, component string) *log.Logger {
-	entry := logrus.WithField("source", component) // Add a field to distinguish source
+	entry := Component(component) // Add a field to distinguish source
 	writer := &logrusWriter{entry: entry, level: level}
 	return log.New(writer, "", 0) // No prefix or flags needed from stdlib logger
 }
@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotation configures when a log file is rotated and how many rotated files are kept.
+type Rotation struct {
+	// Rotate once the file would grow beyond this many megabytes; 0 disables size-based rotation
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// Rotate once the file has been written to for this long, e.g. "24h"; 0 disables age-based rotation
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// Number of rotated files to keep; 0 keeps all of them
+	MaxBackups int `mapstructure:"max_backups"`
+	// Gzip rotated files
+	Compress bool `mapstructure:"compress"`
+}
+
+// rotatedTimeFormat names rotated files, e.g. manager-20250501T120000.000.log. It sorts chronologically.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// rotatingFile is an io.WriteCloser appending to a file that it rotates according to a Rotation. Rotated files are
+// renamed to <name>-<time><ext>, gzipped if configured, and pruned to MaxBackups.
+type rotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time // When the current file started being written to by this process
+}
+
+// openRotatingFile opens path for appending, creating it and its directory if needed.
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, rotation: rotation}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory '%s': %w", filepath.Dir(r.path), err)
+	}
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file '%s': %w", r.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file '%s': %w", r.path, err)
+	}
+	r.file, r.size, r.started = file, info.Size(), time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("log file '%s' is closed", r.path)
+	}
+	if r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			// Keep writing to the current file rather than losing entries.
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file '%s': %v\n", r.path, err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due reports whether writing n more bytes requires a rotation first. An empty file is never rotated.
+func (r *rotatingFile) due(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if max := int64(r.rotation.MaxSizeMB) * 1024 * 1024; max > 0 && r.size+n > max {
+		return true
+	}
+	return r.rotation.MaxAge > 0 && time.Since(r.started) >= r.rotation.MaxAge
+}
+
+// rotate renames the current file, opens a new one and then compresses and prunes the rotated files.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().Format(rotatedTimeFormat), ext)
+	renameErr := os.Rename(r.path, rotated)
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if r.rotation.Compress {
+		if err := gzipFile(rotated); err != nil {
+			return err
+		}
+	}
+	return r.prune()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups.
+func (r *rotatingFile) prune() error {
+	if r.rotation.MaxBackups <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return err
+	}
+	var rotated []string
+	for _, entry := range entries {
+		if !entry.IsDir() && r.isRotation(entry.Name()) {
+			rotated = append(rotated, filepath.Join(filepath.Dir(r.path), entry.Name()))
+		}
+	}
+	sort.Strings(rotated)
+	for len(rotated) > r.rotation.MaxBackups {
+		if err := os.Remove(rotated[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// isRotation reports whether name is a rotated file of r: <base>-<rotatedTimeFormat><ext>, optionally gzipped.
+// Other files sharing the prefix, e.g. manager-debug.log next to manager.log, don't parse as a time.
+func (r *rotatingFile) isRotation(name string) bool {
+	ext := filepath.Ext(r.path)
+	stamp, ok := strings.CutPrefix(name, strings.TrimSuffix(filepath.Base(r.path), ext)+"-")
+	if !ok {
+		return false
+	}
+	stamp = strings.TrimSuffix(stamp, ".gz")
+	if stamp, ok = strings.CutSuffix(stamp, ext); !ok {
+		return false
+	}
+	_, err := time.Parse(rotatedTimeFormat, stamp)
+	return err == nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to compress '%s': %w", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to compress '%s': %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsRotation(t *testing.T) {
+	tests := []struct {
+		path, name string
+		want       bool
+	}{
+		{"/var/log/cm.log", "cm-20250501T120000.000.log", true},
+		{"/var/log/cm.log", "cm-20250501T120000.000.log.gz", true},
+		{"/var/log/cm.log", "cm.log", false},
+		{"/var/log/cm.log", "cm-debug.log", false},
+		{"/var/log/cm.log", "cm-debug-20250501T120000.000.log", false},
+		{"/var/log/cm.log", "cm-20250501T120000.000.txt", false},
+		{"/var/log/transcripts/a.com.log", "a.com-b.net.log", false},
+		{"/var/log/transcripts/a.com.log", "a.com-b.net-20250501T120000.000.log", false},
+		{"/var/log/transcripts/a.com.log", "a.com-20250501T120000.000.log", true},
+		{"/var/log/manager", "manager-20250501T120000.000", true},
+		{"/var/log/manager", "manager-20250501T120000.000.gz", true},
+		{"/var/log/manager", "manager-old", false},
+	}
+	for _, tt := range tests {
+		r := &rotatingFile{path: tt.path}
+		if got := r.isRotation(tt.name); got != tt.want {
+			t.Errorf("isRotation(%q) of %s = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPruneKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"cm.log",
+		"cm-20250501T120000.000.log",
+		"cm-20250502T120000.000.log.gz",
+		"cm-20250503T120000.000.log",
+		"cm-debug.log",
+		"cm-debug-20250501T120000.000.log",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &rotatingFile{path: filepath.Join(dir, "cm.log"), rotation: Rotation{MaxBackups: 1}}
+	if err := r.prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		removed := name == "cm-20250501T120000.000.log" || name == "cm-20250502T120000.000.log.gz"
+		if removed != os.IsNotExist(err) {
+			t.Errorf("%s: removed = %v, want %v", name, os.IsNotExist(err), removed)
+		}
+	}
+}
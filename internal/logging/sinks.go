@@ -0,0 +1,232 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the [logging] table of the configuration file: the log outputs besides stderr.
+type Config struct {
+	// Levels of the entries of one certificate or component, on top of the sinks' levels
+	Overrides   []Override  `mapstructure:"override"`
+	Files       []File      `mapstructure:"file"`
+	Syslog      Syslog      `mapstructure:"syslog"`
+	Journald    Journald    `mapstructure:"journald"`
+	Transcripts Transcripts `mapstructure:"transcripts"`
+}
+
+// File is a log file sink with its own level and format.
+type File struct {
+	Path string `mapstructure:"path" jsonschema:"required"`
+	// Level of the file, defaults to --log-level
+	Level string `mapstructure:"level"`
+	// Format of the file, defaults to log_format
+	Format   string `mapstructure:"format"`
+	Rotation `mapstructure:",squash"`
+}
+
+// Transcripts configures the per-certificate files holding the full output of every certbot run.
+type Transcripts struct {
+	// Directory of the <certificate>.log files; transcripts are disabled when empty
+	Dir      string `mapstructure:"dir"`
+	Rotation `mapstructure:",squash"`
+}
+
+// Validate checks the levels, formats and addresses of the sinks.
+func (c Config) Validate() error {
+	var errs []error
+	for i, file := range c.Files {
+		if file.Path == "" {
+			errs = append(errs, fmt.Errorf("logging.file #%d: path is required", i+1))
+		}
+		if file.Level != "" {
+			if _, err := logrus.ParseLevel(file.Level); err != nil {
+				errs = append(errs, fmt.Errorf("logging.file #%d: %w", i+1, err))
+			}
+		}
+		if file.Format != "" {
+			if _, err := newFormatter(file.Format); err != nil {
+				errs = append(errs, fmt.Errorf("logging.file #%d: %w", i+1, err))
+			}
+		}
+	}
+	for i, override := range c.Overrides {
+		if err := override.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("logging.override #%d: %w", i+1, err))
+		}
+	}
+	errs = append(errs, c.Syslog.Validate(), c.Journald.Validate())
+	return errors.Join(errs...)
+}
+
+// sink writes the entries at or above its level to w, formatted by its formatter, and those an override lets
+// through. Every output, stderr included, is a sink installed as a logrus hook, so each can have its own level.
+type sink struct {
+	mu        sync.Mutex
+	w         io.Writer
+	level     logrus.Level
+	inherit   bool // level is the default one, so the sink follows runtime level changes instead
+	formatter logrus.Formatter
+}
+
+// threshold returns the level of the sink under the levels s.
+func (s *sink) threshold(state *levelState) logrus.Level {
+	if s.inherit {
+		return state.base
+	}
+	return s.level
+}
+
+// Levels returns every level: overrides may let any entry through, so Fire filters them.
+func (s *sink) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (s *sink) Fire(entry *logrus.Entry) error {
+	if state := active.Load(); entry.Level > s.threshold(state) && !state.raises(entry) {
+		return nil
+	}
+	data, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+// discardFormatter skips formatting for logrus' own output, which is discarded in favour of the sinks.
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// outputs holds the sinks installed by the last Setup or Configure, so they can be closed when replaced.
+var outputs struct {
+	sync.Mutex
+	sinks       []*sink
+	closers     []io.Closer
+	transcripts *transcriptWriter
+}
+
+// install replaces the logrus hooks with sinks, puts state in effect as the configured levels, discarding runtime
+// changes, and sets the logger's level to the most verbose a sink or override can write. Closers are the files
+// opened for the sinks, closed when the next install replaces them.
+func install(sinks []*sink, closers []io.Closer, transcripts *transcriptWriter, state *levelState) {
+	hooks := make(logrus.LevelHooks)
+	for _, s := range sinks {
+		hooks.Add(s)
+	}
+
+	outputs.Lock()
+	previous, previousTranscripts := outputs.closers, outputs.transcripts
+	outputs.sinks, outputs.closers, outputs.transcripts = sinks, closers, transcripts
+	outputs.Unlock()
+
+	logrus.SetOutput(io.Discard)
+	logrus.SetFormatter(discardFormatter{})
+	logrus.StandardLogger().ReplaceHooks(hooks)
+	levels.Lock()
+	levels.configured = state
+	apply(state)
+	levels.Unlock()
+
+	closeAll(previous)
+	if previousTranscripts != nil {
+		_ = previousTranscripts.Close()
+	}
+}
+
+// parseLevel parses a level name, falling back to def if it is empty.
+func parseLevel(name string, def logrus.Level) (logrus.Level, error) {
+	if name == "" {
+		return def, nil
+	}
+	return logrus.ParseLevel(strings.ToLower(name))
+}
+
+// Configure sets up stderr like Setup, plus the file, syslog and journald sinks and certbot transcripts of cfg.
+// Files and connections opened by a previous Configure are closed once the new ones are in place, so it can be
+// called again on reload.
+func Configure(levelStr, format string, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	stderr, err := stderrSink(levelStr, format)
+	if err != nil {
+		return err
+	}
+
+	sinks := []*sink{stderr}
+	var closers []io.Closer
+	for _, file := range cfg.Files {
+		level, _ := parseLevel(file.Level, stderr.level)
+		inherit := file.Level == ""
+		fileFormat := file.Format
+		if fileFormat == "" {
+			fileFormat = format
+		}
+		formatter, _ := newFormatter(fileFormat)
+		w, err := openRotatingFile(file.Path, file.Rotation)
+		if err != nil {
+			closeAll(closers)
+			return err
+		}
+		sinks = append(sinks, &sink{w: w, level: level, inherit: inherit, formatter: formatter})
+		closers = append(closers, w)
+	}
+	if cfg.Syslog.Enabled() {
+		s, w, err := syslogSink(cfg.Syslog, stderr.level)
+		if err != nil {
+			closeAll(closers)
+			return err
+		}
+		sinks = append(sinks, s)
+		closers = append(closers, w)
+	}
+	if cfg.Journald.Enabled {
+		s, w, err := journalSink(cfg.Journald, stderr.level)
+		if err != nil {
+			closeAll(closers)
+			return err
+		}
+		sinks = append(sinks, s)
+		closers = append(closers, w)
+	}
+
+	var transcripts *transcriptWriter
+	if cfg.Transcripts.Dir != "" {
+		transcripts = &transcriptWriter{dir: cfg.Transcripts.Dir, rotation: cfg.Transcripts.Rotation, files: map[string]*rotatingFile{}}
+	}
+	install(sinks, closers, transcripts, newLevelState(stderr.level, cfg.Overrides))
+	return nil
+}
+
+// stderrSink returns the sink writing to stderr. An invalid level falls back to info with a warning.
+func stderrSink(levelStr, format string) (*sink, error) {
+	formatter, err := newFormatter(format)
+	if err != nil {
+		return nil, err
+	}
+	level, err := parseLevel(levelStr, logrus.InfoLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Invalid log level '%s' provided: %v. Defaulting to 'info'.\n", levelStr, err)
+		level = logrus.InfoLevel
+	}
+	return &sink{w: os.Stderr, level: level, inherit: true, formatter: formatter}, nil
+}
+
+// closeAll closes the files and connections of sinks.
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,299 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Values accepted by `logging.syslog.network`.
+const (
+	SyslogUnix = "unix" // Local socket, /dev/log unless address is set
+	SyslogUDP  = "udp"
+	SyslogTCP  = "tcp" // Messages are framed by octet counting (RFC 6587)
+)
+
+// SyslogNetworks lists the values accepted by `logging.syslog.network`.
+var SyslogNetworks = []string{SyslogUnix, SyslogUDP, SyslogTCP}
+
+// facilities maps the names accepted by `logging.syslog.facility` to their codes.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7, "uucp": 8, "cron": 9,
+	"authpriv": 10, "ftp": 11, "local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21,
+	"local6": 22, "local7": 23,
+}
+
+// Facilities lists the values accepted by `logging.syslog.facility`.
+var Facilities = sortedKeys(facilities)
+
+// localSyslogSockets are tried in order when `logging.syslog.network` is unix without an address.
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+const (
+	defaultAppName  = "certbot-manager"
+	defaultFacility = "daemon"
+	// syslogSDID is the SD-ID of the structured data element holding the entry's fields. 32473 is the private
+	// enterprise number reserved for documentation (RFC 5612).
+	syslogSDID = "certbot-manager@32473"
+	// syslogDialTimeout bounds connecting to a UDP or TCP collector.
+	syslogDialTimeout = 5 * time.Second
+)
+
+// Syslog is a sink sending RFC 5424 messages to a syslog daemon or collector.
+type Syslog struct {
+	// Transport: unix, udp or tcp; syslog is off when empty
+	Network string `mapstructure:"network"`
+	// Socket path for unix, host:port for udp and tcp
+	Address string `mapstructure:"address"`
+	// Level of the sink, defaults to --log-level
+	Level string `mapstructure:"level"`
+	// Format of the message part; by default the plain message, with the fields as structured data
+	Format string `mapstructure:"format"`
+	// Syslog facility, defaults to daemon
+	Facility string `mapstructure:"facility"`
+	// APP-NAME of the messages, defaults to certbot-manager
+	AppName string `mapstructure:"app_name"`
+}
+
+// Enabled reports whether s selects a transport.
+func (s Syslog) Enabled() bool {
+	return s.Network != ""
+}
+
+// Validate checks the transport, address, level, format and facility.
+func (s Syslog) Validate() error {
+	if !s.Enabled() {
+		return nil
+	}
+	var errs []error
+	switch s.Network {
+	case SyslogUnix:
+	case SyslogUDP, SyslogTCP:
+		if s.Address == "" {
+			errs = append(errs, fmt.Errorf("logging.syslog: address is required for network '%s'", s.Network))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("logging.syslog: unknown network '%s' (options: %v)", s.Network, SyslogNetworks))
+	}
+	if s.Level != "" {
+		if _, err := logrus.ParseLevel(s.Level); err != nil {
+			errs = append(errs, fmt.Errorf("logging.syslog: %w", err))
+		}
+	}
+	if s.Format != "" {
+		if _, err := newFormatter(s.Format); err != nil {
+			errs = append(errs, fmt.Errorf("logging.syslog: %w", err))
+		}
+	}
+	if _, ok := facilities[s.Facility]; s.Facility != "" && !ok {
+		errs = append(errs, fmt.Errorf("logging.syslog: unknown facility '%s' (options: %v)", s.Facility, Facilities))
+	}
+	return errors.Join(errs...)
+}
+
+// syslogSink returns the sink sending to the syslog daemon of cfg, at level unless cfg sets its own.
+func syslogSink(cfg Syslog, level logrus.Level) (*sink, *syslogWriter, error) {
+	level, _ = parseLevel(cfg.Level, level)
+	formatter := &syslogFormatter{appName: cfg.AppName, facility: facilities[defaultFacility], pid: os.Getpid()}
+	if formatter.appName == "" {
+		formatter.appName = defaultAppName
+	}
+	if cfg.Facility != "" {
+		formatter.facility = facilities[cfg.Facility]
+	}
+	if cfg.Format != "" {
+		formatter.body, _ = newFormatter(cfg.Format)
+	}
+	formatter.hostname, _ = os.Hostname()
+
+	w := &syslogWriter{network: cfg.Network, address: cfg.Address}
+	if err := w.connect(); err != nil {
+		return nil, nil, err
+	}
+	return &sink{w: w, level: level, inherit: cfg.Level == "", formatter: formatter}, w, nil
+}
+
+// syslogSeverity maps a logrus level to a syslog severity, also used as the journal's PRIORITY.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	}
+	return 7 // debug
+}
+
+// syslogFormatter formats entries as RFC 5424 messages.
+type syslogFormatter struct {
+	appName  string
+	hostname string
+	facility int
+	pid      int
+	body     logrus.Formatter // Formats the message part when set; the fields are then left out of the structured data
+}
+
+func (f *syslogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ", f.facility*8+syslogSeverity(entry.Level),
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"), syslogHeaderValue(f.hostname, 255),
+		syslogHeaderValue(f.appName, 48), f.pid, syslogHeaderValue(fmt.Sprint(entry.Data[FieldComponent]), 32))
+
+	if f.body != nil {
+		body, err := f.body.Format(entry)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString("- ")
+		b.Write(bytes.TrimSuffix(body, []byte("\n")))
+		return b.Bytes(), nil
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if key != FieldComponent {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		b.WriteByte('-')
+	} else {
+		sort.Strings(keys)
+		b.WriteString("[" + syslogSDID)
+		for _, key := range keys {
+			fmt.Fprintf(&b, ` %s="%s"`, syslogHeaderValue(key, 32), syslogParamEscaper.Replace(fieldString(entry.Data[key])))
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte(' ')
+	b.WriteString(entry.Message)
+	return b.Bytes(), nil
+}
+
+// syslogParamEscaper escapes the characters RFC 5424 reserves in structured data values.
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderValue makes s a valid header field: printable ASCII without spaces, at most n characters, or "-".
+func syslogHeaderValue(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "<nil>" {
+		return "-"
+	}
+	if len(s) > n {
+		s = s[:n]
+	}
+	return s
+}
+
+// syslogWriter sends each Write as one message. A broken connection, e.g. after the daemon restarted, is
+// reopened once per message before giving up.
+type syslogWriter struct {
+	network string
+	address string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool // The connection is a stream, so messages need framing
+}
+
+func (w *syslogWriter) connect() error {
+	if w.network != SyslogUnix {
+		conn, err := net.DialTimeout(w.network, w.address, syslogDialTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog at %s://%s: %w", w.network, w.address, err)
+		}
+		w.conn, w.stream = conn, w.network == SyslogTCP
+		return nil
+	}
+
+	addresses := localSyslogSockets
+	if w.address != "" {
+		addresses = []string{w.address}
+	}
+	var err error
+	for _, address := range addresses {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			if conn, err = net.Dial(network, address); err == nil {
+				w.conn, w.stream = conn, network == "unix"
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("failed to connect to the syslog socket (tried %s): %w", strings.Join(addresses, ", "), err)
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msg := p
+	if w.stream {
+		msg = w.frame(p)
+	}
+	if w.conn != nil {
+		if _, err := w.conn.Write(msg); err == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	if w.stream {
+		msg = w.frame(p)
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// frame delimits a message on a stream: octet counting over TCP, a newline on a local stream socket.
+func (w *syslogWriter) frame(p []byte) []byte {
+	if w.network == SyslogTCP {
+		return append([]byte(strconv.Itoa(len(p))+" "), p...)
+	}
+	return append(p[:len(p):len(p)], '\n')
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transcript is the complete record of one certbot run.
+type Transcript struct {
+	Name     string        // Certificate name, or the certbot command for runs that cover every lineage
+	Command  string        // Command line, secrets masked
+	Fields   logrus.Fields // Context fields of the run, e.g. run_id and attempt
+	Start    time.Time
+	Duration time.Duration
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// WriteTranscript appends t to <dir>/<name>.log when transcripts are configured. Failures are logged, not
+// returned: a transcript must never fail a certbot run.
+func WriteTranscript(t Transcript) {
+	outputs.Lock()
+	w := outputs.transcripts
+	outputs.Unlock()
+	if w == nil || t.Name == "" {
+		return
+	}
+	if err := w.write(t); err != nil {
+		Component("logging").Warnf("Failed to write certbot transcript for '%s': %v", t.Name, err)
+	}
+}
+
+// transcriptWriter holds a rotating file per transcript name.
+type transcriptWriter struct {
+	dir      string
+	rotation Rotation
+
+	mu    sync.Mutex
+	files map[string]*rotatingFile
+}
+
+func (w *transcriptWriter) write(t Transcript) error {
+	// Names are lineage names or certbot commands; never let one escape the directory.
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(t.Name)
+
+	w.mu.Lock()
+	file, ok := w.files[name]
+	if !ok {
+		var err error
+		if file, err = openRotatingFile(filepath.Join(w.dir, name+".log"), w.rotation); err != nil {
+			w.mu.Unlock()
+			return err
+		}
+		w.files[name] = file
+	}
+	w.mu.Unlock()
+
+	_, err := file.Write(formatTranscript(t))
+	return err
+}
+
+func (w *transcriptWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, file := range w.files {
+		_ = file.Close()
+	}
+	w.files = map[string]*rotatingFile{}
+	return nil
+}
+
+// formatTranscript renders a run as a block: a header with the start time and fields, the command line, the
+// output streams and the exit code.
+func formatTranscript(t Transcript) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "=== %s", t.Start.UTC().Format(structuredTimestampFormat))
+	keys := make([]string, 0, len(t.Fields))
+	for key := range t.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, textValue(t.Fields[key]))
+	}
+	fmt.Fprintf(&b, "\n$ %s\n", t.Command)
+	for _, stream := range []struct{ name, output string }{{"stdout", t.Stdout}, {"stderr", t.Stderr}} {
+		if stream.output == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "--- %s\n%s\n", stream.name, strings.TrimRight(stream.output, "\n"))
+	}
+	fmt.Fprintf(&b, "=== exit code %d after %s\n\n", t.ExitCode, t.Duration.Round(time.Millisecond))
+	return b.Bytes()
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/letsencrypt"
+)
+
+// lineages reports the expiry of the live certificate of every lineage, labelled with the lineage's name like the
+// other certificate metrics. Days until expiry are computed at scrape time, so they stay accurate between certbot runs.
+var lineages = &lineageCollector{
+	notAfter: prometheus.NewDesc(prometheus.BuildFQName(namespace, "certificate", "not_after_timestamp_seconds"),
+		"Expiry time of the lineage's live certificate.", []string{"certificate"}, nil),
+	daysLeft: prometheus.NewDesc(prometheus.BuildFQName(namespace, "certificate", "days_until_expiry"),
+		"Days until the lineage's live certificate expires.", []string{"certificate"}, nil),
+}
+
+type lineageCollector struct {
+	notAfter, daysLeft *prometheus.Desc
+
+	mu      sync.Mutex
+	expires map[string]time.Time // Lineage name to NotAfter
+}
+
+// ObserveLineages reads the lineages in certbot's configuration directory and records the expiry of their live
+// certificates, replacing what was recorded before.
+func ObserveLineages(configDir string) {
+	found, err := letsencrypt.Lineages(configDir)
+	if err != nil {
+		logrus.Debugf("Failed to read lineages for metrics: %v", err)
+		return
+	}
+	expires := make(map[string]time.Time, len(found))
+	for _, lineage := range found {
+		if lineage.Cert != nil {
+			expires[lineage.Name] = lineage.Cert.NotAfter
+		}
+	}
+
+	lineages.mu.Lock()
+	lineages.expires = expires
+	lineages.mu.Unlock()
+}
+
+func (c *lineageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.notAfter
+	ch <- c.daysLeft
+}
+
+func (c *lineageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for name, notAfter := range c.expires {
+		ch <- prometheus.MustNewConstMetric(c.notAfter, prometheus.GaugeValue, float64(notAfter.Unix()), name)
+		ch <- prometheus.MustNewConstMetric(c.daysLeft, prometheus.GaugeValue, notAfter.Sub(now).Hours()/24, name)
+	}
+}
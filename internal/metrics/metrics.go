@@ -0,0 +1,185 @@
+// Package metrics exposes the manager's Prometheus metrics. The certbot runner, the scheduler and the daemon record
+// into it; Handler serves them.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "certbot_manager"
+
+// registry holds the manager's metrics, along with the Go runtime and process collectors.
+var registry = prometheus.NewRegistry()
+
+var (
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "certbot_run_duration_seconds",
+		Help:      "Duration of certbot runs, by certbot command and authenticator.",
+		Buckets:   []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"cmd", "authenticator"})
+
+	runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "certbot_runs_total",
+		Help:      "Certbot runs, by certbot command and outcome (success or the error class).",
+	}, []string{"cmd", "outcome"})
+
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_last_success_timestamp_seconds",
+		Help:      "Time of the last successful certbot run for a certificate.",
+	}, []string{"certificate"})
+
+	lastFailure = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_last_failure_timestamp_seconds",
+		Help:      "Time of the last failed certbot run for a certificate.",
+	}, []string{"certificate"})
+
+	nextRenewal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "next_renewal_timestamp_seconds",
+		Help:      "Time the renewal job is scheduled to run next.",
+	})
+
+	scheduledRuns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduled_renewals_total",
+		Help:      "Renewal jobs started by the scheduler.",
+	})
+
+	reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Configuration reloads, by result (success or failure).",
+	}, []string{"result"})
+
+	lastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload succeeded (1) or failed (0).",
+	})
+
+	lastReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Time of the last successful configuration load or reload.",
+	})
+
+	expiryNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watchdog_not_after_timestamp_seconds",
+		Help:      "Expiry time of a certificate file checked by the expiry watchdog.",
+	}, []string{"certificate", "path"})
+
+	expirySeverity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watchdog_severity",
+		Help:      "Severity of a certificate file's expiry: 0 ok, 1 warning, 2 critical, 3 expired, 4 unreadable.",
+	}, []string{"certificate", "path"})
+
+	expiryEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watchdog_events_total",
+		Help:      "Events raised by the expiry watchdog, by severity.",
+	}, []string{"severity"})
+
+	expiryLastCheck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watchdog_last_check_timestamp_seconds",
+		Help:      "Time of the last expiry watchdog check.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		runDuration, runs, lastSuccess, lastFailure, nextRenewal, scheduledRuns,
+		reloads, lastReloadSuccessful, lastReloadSuccess,
+		expiryNotAfter, expirySeverity, expiryEvents, expiryLastCheck,
+		lineages,
+	)
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccess.SetToCurrentTime()
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveRun records a finished certbot run. outcome is "success" or the class of its error.
+func ObserveRun(cmd, authenticator string, duration time.Duration, outcome string) {
+	runDuration.WithLabelValues(cmd, authenticator).Observe(duration.Seconds())
+	runs.WithLabelValues(cmd, outcome).Inc()
+}
+
+// ObserveFailedRun counts a certbot run that failed before certbot was started, such as one whose arguments couldn't
+// be built. outcome is the class of its error.
+func ObserveFailedRun(cmd, outcome string) {
+	runs.WithLabelValues(cmd, outcome).Inc()
+}
+
+// ObserveCertificate records the outcome of a certbot run for a certificate, named like its lineage.
+func ObserveCertificate(name string, succeeded bool, at time.Time) {
+	if succeeded {
+		lastSuccess.WithLabelValues(name).Set(float64(at.Unix()))
+	} else {
+		lastFailure.WithLabelValues(name).Set(float64(at.Unix()))
+	}
+}
+
+// SetNextRenewal records when the renewal job runs next.
+func SetNextRenewal(next time.Time) {
+	nextRenewal.Set(float64(next.Unix()))
+}
+
+// ObserveScheduledRun counts a renewal job started by the scheduler.
+func ObserveScheduledRun() {
+	scheduledRuns.Inc()
+}
+
+// ObserveReload records the result of a configuration reload.
+func ObserveReload(err error) {
+	if err != nil {
+		reloads.WithLabelValues("failure").Inc()
+		lastReloadSuccessful.Set(0)
+		return
+	}
+	reloads.WithLabelValues("success").Inc()
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccess.SetToCurrentTime()
+}
+
+// ExpiryCheck is the outcome of the expiry watchdog for one certificate file.
+type ExpiryCheck struct {
+	Name     string
+	Path     string
+	NotAfter time.Time // Zero if the file couldn't be read
+	Severity int       // See watchdog_severity
+}
+
+// ObserveExpiryCheck records a run of the expiry watchdog, replacing the certificates recorded by the last one.
+func ObserveExpiryCheck(at time.Time, checks []ExpiryCheck) {
+	expiryNotAfter.Reset()
+	expirySeverity.Reset()
+	for _, check := range checks {
+		if !check.NotAfter.IsZero() {
+			expiryNotAfter.WithLabelValues(check.Name, check.Path).Set(float64(check.NotAfter.Unix()))
+		}
+		expirySeverity.WithLabelValues(check.Name, check.Path).Set(float64(check.Severity))
+	}
+	expiryLastCheck.Set(float64(at.Unix()))
+}
+
+// ObserveExpiryEvent counts an event raised by the expiry watchdog.
+func ObserveExpiryEvent(severity string) {
+	expiryEvents.WithLabelValues(severity).Inc()
+}
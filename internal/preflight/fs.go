@@ -0,0 +1,58 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// CheckWritableDir reports whether a directory exists and files can be created in it, which also catches
+// read-only mounts. A missing directory is reported with os.ErrNotExist.
+func CheckWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	file, err := os.CreateTemp(dir, ".certbot-manager-doctor-*")
+	if err != nil {
+		if errors.Is(err, syscall.EROFS) {
+			return fmt.Errorf("'%s' is on a read-only file system", dir)
+		}
+		return fmt.Errorf("can't create files in '%s': %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// CheckDirectory checks a directory the manager or certbot writes to. A missing directory is only a warning when
+// its closest existing parent is writable, since it is then created on demand.
+func CheckDirectory(check, dir string) Result {
+	err := CheckWritableDir(dir)
+	switch {
+	case err == nil:
+		return Pass(check, "'%s' is writable", dir)
+	case errors.Is(err, os.ErrNotExist):
+		parent := filepath.Dir(dir)
+		for parent != filepath.Dir(parent) {
+			if _, statErr := os.Stat(parent); statErr == nil {
+				break
+			}
+			parent = filepath.Dir(parent)
+		}
+		if parentErr := CheckWritableDir(parent); parentErr != nil {
+			return Fail(check, fmt.Sprintf("create '%s' or make '%s' writable", dir, parent),
+				"'%s' doesn't exist and can't be created: %v", dir, parentErr)
+		}
+		return Warn(check, "it is created on first use; create it beforehand to choose its owner and permissions",
+			"'%s' doesn't exist yet", dir)
+	default:
+		return Fail(check, fmt.Sprintf("make '%s' writable for the user running certbot-manager, and mount it read-write", dir),
+			"%v", err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package preflight runs the environment checks of `certbot-manager doctor`. Checks are registered from init()
+// functions, globally or per certificate; authenticators contribute their own by implementing
+// authenticators.Preflight.
+package preflight
+
+import (
+	"fmt"
+
+	"certbot-manager/internal/config"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+// Check outcomes, from best to worst.
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is a single finding of a check. Hint tells how to fix a warning or failure.
+type Result struct {
+	Scope   string `json:"scope"` // "global" or "certificate[<index>]"
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Pass returns a passing result.
+func Pass(check, format string, args ...any) Result {
+	return Result{Check: check, Status: StatusPass, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warn returns a warning with a fix hint.
+func Warn(check, hint, format string, args ...any) Result {
+	return Result{Check: check, Status: StatusWarn, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Fail returns a failure with a fix hint.
+func Fail(check, hint, format string, args ...any) Result {
+	return Result{Check: check, Status: StatusFail, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Env is what checks know about the environment.
+type Env struct {
+	Config      *config.Config
+	CertbotPath string   // Resolved certbot executable, empty if it wasn't found
+	CertbotErr  error    // Why CertbotPath is empty
+	Plugins     []string // Installed certbot plugins, nil if they couldn't be listed
+	Offline     bool     // Skip checks that need network access
+}
+
+// HasPlugin reports whether the named certbot plugin is installed. It returns true when the plugins are unknown.
+func (e Env) HasPlugin(name string) bool {
+	if e.Plugins == nil {
+		return true
+	}
+	for _, plugin := range e.Plugins {
+		if plugin == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobalCheck checks the environment shared by every certificate.
+type GlobalCheck func(env Env) []Result
+
+// CertificateCheck checks the environment of one certificate.
+type CertificateCheck func(env Env, cert config.Certificate) []Result
+
+var (
+	globalChecks      []GlobalCheck
+	certificateChecks []CertificateCheck
+)
+
+// RegisterGlobal adds a check run once. Called from init() functions.
+func RegisterGlobal(check GlobalCheck) {
+	globalChecks = append(globalChecks, check)
+}
+
+// RegisterCertificate adds a check run for every configured certificate. Called from init() functions.
+func RegisterCertificate(check CertificateCheck) {
+	certificateChecks = append(certificateChecks, check)
+}
+
+// Run runs every registered check, global checks first, in registration order.
+func Run(env Env) []Result {
+	var results []Result
+	for _, check := range globalChecks {
+		results = append(results, scoped("global", check(env))...)
+	}
+	for i, cert := range env.Config.Certificates {
+		results = append(results, scoped(fmt.Sprintf("certificate[%d]", i), RunCertificate(env, cert))...)
+	}
+	return results
+}
+
+// RunCertificate runs the checks registered for every certificate on cert, in registration order.
+func RunCertificate(env Env, cert config.Certificate) []Result {
+	var results []Result
+	for _, check := range certificateChecks {
+		results = append(results, scoped("certificate", check(env, cert))...)
+	}
+	return results
+}
+
+// Worst returns the worst status among results, StatusPass if there are none.
+func Worst(results []Result) Status {
+	worst := StatusPass
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return StatusFail
+		}
+		if result.Status == StatusWarn {
+			worst = StatusWarn
+		}
+	}
+	return worst
+}
+
+// scoped sets the scope of results.
+func scoped(scope string, results []Result) []Result {
+	for i := range results {
+		results[i].Scope = scope
+	}
+	return results
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package state
+
+import "os"
+
+// lockFile does nothing: without flock, concurrent updates may overwrite each other.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,190 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileName is the name of the state file inside the state directory.
+const fileName = "state.json"
+
+// State is the manager-owned record of the lineages certbot-manager manages, persisted as JSON in the state
+// directory. Only lineages recorded here are ever considered orphans, so lineages created by other tools
+// are never touched.
+type State struct {
+	Lineages map[string]*Lineage `json:"lineages"`
+	Actions  []Action            `json:"actions,omitempty"`
+}
+
+// Lineage is what the manager remembers about a lineage it manages.
+type Lineage struct {
+	Domains        []string  `json:"domains"`
+	Server         string    `json:"server,omitempty"`
+	RecordedAt     time.Time `json:"recorded_at"`
+	RenewalStopped bool      `json:"renewal_stopped,omitempty"`
+	LastRun        *Run      `json:"last_run,omitempty"`
+}
+
+// Run is the outcome of the last certbot run that covered a lineage.
+type Run struct {
+	Command   string    `json:"command"` // certbot subcommand, e.g. "certonly" or "renew"
+	At        time.Time `json:"at"`
+	Succeeded bool      `json:"succeeded"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Action is a manual operation on a lineage, such as `certbot-manager revoke`.
+type Action struct {
+	Action    string    `json:"action"` // "revoke" or "delete"
+	Lineage   string    `json:"lineage"`
+	Domains   []string  `json:"domains"`
+	Reason    string    `json:"reason,omitempty"`
+	At        time.Time `json:"at"`
+	Succeeded bool      `json:"succeeded"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// maxActions bounds the manual operations kept in the state; the audit log keeps the full record.
+const maxActions = 100
+
+// RecordAction appends a finished manual operation to the state, dropping the oldest beyond maxActions. err is the
+// operation's outcome.
+func (s *State) RecordAction(action Action, err error) {
+	action.At = time.Now().UTC()
+	action.Succeeded = err == nil
+	if err != nil {
+		action.Error = err.Error()
+	}
+	s.Actions = append(s.Actions, action)
+	if len(s.Actions) > maxActions {
+		s.Actions = append([]Action(nil), s.Actions[len(s.Actions)-maxActions:]...)
+	}
+}
+
+// NewRun records the outcome of a certbot command that just finished.
+func NewRun(command string, err error) *Run {
+	run := &Run{Command: command, At: time.Now().UTC(), Succeeded: err == nil}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// Open reads the state file from dir, returning an empty state if it doesn't exist yet. The state is a snapshot:
+// changes are made with Update.
+func Open(dir string) (*State, error) {
+	path := Path(dir)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read state file '%s': %w", path, err)
+	}
+	return decode(path, data)
+}
+
+// Update applies change to the state file in dir and writes it back atomically, creating the state directory if
+// needed. The file is locked from reading to writing, so the daemon and commands such as `revoke` can update it
+// concurrently without losing each other's changes. Nothing is written if change fails.
+func Update(dir string, change func(*State) error) error {
+	path := Path(dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory '%s': %w", dir, err)
+	}
+	file, err := openLocked(path)
+	if err != nil {
+		return fmt.Errorf("failed to open state file '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read state file '%s': %w", path, err)
+	}
+	s, err := decode(path, data)
+	if err != nil {
+		return err
+	}
+	if err := change(s); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, fileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state file '%s': %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// decode parses the content of the state file at path; empty content is an empty state.
+func decode(path string, data []byte) (*State, error) {
+	s := &State{Lineages: map[string]*Lineage{}}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("failed to parse state file '%s': %w", path, err)
+		}
+	}
+	if s.Lineages == nil {
+		s.Lineages = map[string]*Lineage{}
+	}
+	return s, nil
+}
+
+// openLocked opens the state file at path, creating it empty if needed, and locks it exclusively until it is
+// closed. Update replaces the file, so a file that was replaced while waiting for the lock is reopened.
+func openLocked(path string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock: %w", err)
+		}
+		locked, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return file, nil
+		}
+		file.Close()
+	}
+}
+
+// Path returns the location of the state file in the state directory.
+func Path(dir string) string {
+	return filepath.Join(dir, fileName)
+}
+
+// LineageNames returns the names of the recorded lineages, sorted.
+func (s *State) LineageNames() []string {
+	names := make([]string, 0, len(s.Lineages))
+	for name := range s.Lineages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
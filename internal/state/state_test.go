@@ -0,0 +1,91 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestUpdateConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := Update(dir, func(s *State) error {
+				s.Lineages[fmt.Sprintf("lineage%d", i)] = &Lineage{Domains: []string{fmt.Sprintf("%d.example.org", i)}}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			err := Update(dir, func(s *State) error {
+				s.RecordAction(Action{Action: "delete", Lineage: fmt.Sprintf("other%d", i)}, nil)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Lineages) != n || len(s.Actions) != n {
+		t.Errorf("%d lineages and %d actions after %d concurrent updates of each, want %d", len(s.Lineages), len(s.Actions), n, n)
+	}
+}
+
+func TestUpdateFailureWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	if err := Update(dir, func(s *State) error {
+		s.Lineages["a"] = &Lineage{}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(Path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err = Update(dir, func(s *State) error {
+		delete(s.Lineages, "a")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("Update = %v, want the change's error", err)
+	}
+	after, err := os.ReadFile(Path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("state changed by a failed update:\n%s", after)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("state directory holds %d files, want only the state file", len(entries))
+	}
+}
+
+func TestRecordActionKeepsTheLast(t *testing.T) {
+	s := &State{}
+	for i := 0; i < maxActions+5; i++ {
+		s.RecordAction(Action{Lineage: fmt.Sprint(i)}, nil)
+	}
+	if len(s.Actions) != maxActions || s.Actions[0].Lineage != "5" || s.Actions[maxActions-1].Lineage != fmt.Sprint(maxActions+4) {
+		t.Errorf("kept %d actions from %s to %s", len(s.Actions), s.Actions[0].Lineage, s.Actions[len(s.Actions)-1].Lineage)
+	}
+}
@@ -0,0 +1,370 @@
+// Package tomledit edits TOML documents in place, keeping the comments, ordering and formatting of every line it
+// doesn't touch. It understands tables, arrays of tables and key/value pairs, which is all config.toml uses.
+package tomledit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidEdit is returned by an edit that would leave the document unparsable, such as setting a key to a
+// malformed value. The document is left as it was.
+var ErrInvalidEdit = errors.New("edit produced an invalid document")
+
+// Document is a TOML document split into lines.
+// Every edit re-parses the document, so tables obtained before an edit must not be used after it.
+type Document struct {
+	lines  []string
+	tables []*Table // tables[0] holds the keys before the first header
+}
+
+// Table is a `[name]` or `[[name]]` section of a Document.
+type Table struct {
+	Name  string
+	Array bool
+
+	doc    *Document
+	header int // Line of the header, -1 for the root table
+	end    int // Line after the last key of the table
+	keys   []keyValue
+}
+
+// keyValue locates a `key = value` pair; a value may span several lines.
+type keyValue struct {
+	key        string
+	start, end int // Lines [start, end]
+	valueCol   int // Column of the value on the start line
+	commentCol int // Column of the trailing comment on the end line, -1 if none
+}
+
+// Parse splits data into tables and key/value pairs.
+func Parse(data []byte) (*Document, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	d := &Document{lines: strings.Split(strings.TrimSuffix(text, "\n"), "\n")}
+	if text == "" {
+		d.lines = nil
+	}
+	if err := d.parse(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() []byte {
+	if len(d.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(d.lines, "\n") + "\n")
+}
+
+// Tables returns the tables with the given name, in document order. For an array of tables it returns one Table
+// per `[[name]]` element.
+func (d *Document) Tables(name string) []*Table {
+	var tables []*Table
+	for _, t := range d.tables[1:] {
+		if t.Name == name {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+func (d *Document) parse() error {
+	root := &Table{doc: d, header: -1}
+	d.tables = []*Table{root}
+	current := root
+	for i := 0; i < len(d.lines); i++ {
+		line := strings.TrimSpace(d.lines[i])
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			name, array, err := parseHeader(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current = &Table{Name: name, Array: array, doc: d, header: i, end: i + 1}
+			d.tables = append(d.tables, current)
+		default:
+			kv, err := parseKeyValue(d.lines, i)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current.keys = append(current.keys, kv)
+			current.end = kv.end + 1
+			i = kv.end
+		}
+	}
+	return nil
+}
+
+// parseHeader parses a `[name]` or `[[name]]` line, ignoring a trailing comment.
+func parseHeader(line string) (string, bool, error) {
+	if comment := strings.Index(line, "#"); comment >= 0 {
+		line = strings.TrimSpace(line[:comment])
+	}
+	array := strings.HasPrefix(line, "[[")
+	open, close := "[", "]"
+	if array {
+		open, close = "[[", "]]"
+	}
+	if !strings.HasSuffix(line, close) {
+		return "", false, fmt.Errorf("invalid table header %q", line)
+	}
+	name := strings.TrimSpace(line[len(open) : len(line)-len(close)])
+	if name == "" {
+		return "", false, fmt.Errorf("invalid table header %q", line)
+	}
+	return name, array, nil
+}
+
+// parseKeyValue parses the pair starting on line i.
+func parseKeyValue(lines []string, i int) (keyValue, error) {
+	line := lines[i]
+	eq := keyEnd(line)
+	if eq < 0 {
+		return keyValue{}, fmt.Errorf("expected 'key = value', found %q", strings.TrimSpace(line))
+	}
+	kv := keyValue{key: unquoteKey(strings.TrimSpace(line[:eq])), start: i}
+	kv.valueCol = eq + 1
+	for kv.valueCol < len(line) && (line[kv.valueCol] == ' ' || line[kv.valueCol] == '\t') {
+		kv.valueCol++
+	}
+	end, commentCol, err := valueEnd(lines, i, kv.valueCol)
+	if err != nil {
+		return keyValue{}, fmt.Errorf("key '%s': %w", kv.key, err)
+	}
+	kv.end, kv.commentCol = end, commentCol
+	return kv, nil
+}
+
+// keyEnd returns the column of the '=' separating the key from the value, -1 if there is none.
+func keyEnd(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		case c == '#':
+			return -1
+		}
+	}
+	return -1
+}
+
+// unquoteKey strips the quotes of a quoted key.
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// valueEnd scans a value starting at lines[line][col] and returns the line it ends on and the column of the
+// comment following it on that line (-1 if none). Arrays, inline tables and multi-line strings may span lines.
+func valueEnd(lines []string, line, col int) (int, int, error) {
+	depth := 0
+	var quote string // Delimiter of the string being scanned: ", ', """ or '''
+	for ; line < len(lines); line, col = line+1, 0 {
+		s := lines[line]
+		comment := -1
+	scan:
+		for i := col; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case quote != "":
+				if c == '\\' && quote[0] == '"' {
+					i++
+				} else if strings.HasPrefix(s[i:], quote) {
+					i += len(quote) - 1
+					quote = ""
+				}
+			case c == '#':
+				comment = i
+				break scan
+			case c == '"' || c == '\'':
+				quote = string(c)
+				if strings.HasPrefix(s[i:], strings.Repeat(quote, 3)) {
+					quote = strings.Repeat(quote, 3)
+					i += 2
+				}
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			}
+		}
+		if quote == `"` || quote == `'` {
+			return 0, 0, fmt.Errorf("unterminated string")
+		}
+		if quote == "" && depth <= 0 {
+			return line, comment, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unterminated value")
+}
+
+// Get returns the raw TOML text of a key's value, without its trailing comment.
+func (t *Table) Get(key string) (string, bool) {
+	kv, ok := t.find(key)
+	if !ok {
+		return "", false
+	}
+	lines := t.doc.lines
+	if kv.start == kv.end {
+		return strings.TrimSpace(cut(lines[kv.start], kv.commentCol)[kv.valueCol:]), true
+	}
+	parts := []string{lines[kv.start][kv.valueCol:]}
+	parts = append(parts, lines[kv.start+1:kv.end]...)
+	parts = append(parts, cut(lines[kv.end], kv.commentCol))
+	return strings.TrimSpace(strings.Join(parts, "\n")), true
+}
+
+// Set sets a key to value, a rendered TOML value (see Value). An existing value is replaced in place, keeping its
+// trailing comment; a new key is added after the table's last key, indented like its other keys.
+func (t *Table) Set(key, value string) error {
+	d := t.doc
+	if kv, ok := t.find(key); ok {
+		line := d.lines[kv.start][:kv.valueCol] + value
+		if kv.commentCol >= 0 {
+			end := d.lines[kv.end]
+			line += end[len(strings.TrimRight(end[:kv.commentCol], " \t")):]
+		}
+		return d.splice(kv.start, kv.end+1, line)
+	}
+	at := t.end
+	if t.header < 0 && len(t.keys) == 0 {
+		at = 0
+	}
+	return d.splice(at, at, t.indent()+formatKey(key)+" = "+value)
+}
+
+// Delete removes a key and reports whether it was set.
+func (t *Table) Delete(key string) (bool, error) {
+	kv, ok := t.find(key)
+	if !ok {
+		return false, nil
+	}
+	return true, t.doc.splice(kv.start, kv.end+1)
+}
+
+// Keys returns the table's keys in document order.
+func (t *Table) Keys() []string {
+	keys := make([]string, len(t.keys))
+	for i, kv := range t.keys {
+		keys[i] = kv.key
+	}
+	return keys
+}
+
+// Remove deletes the table: its header, keys and the comments between them, and the blank lines that follow it.
+// Comments right above the header are kept, as they often describe a whole group of tables.
+func (d *Document) Remove(t *Table) error {
+	end := t.end
+	for end < len(d.lines) && strings.TrimSpace(d.lines[end]) == "" {
+		end++
+	}
+	start := t.header
+	if end == len(d.lines) {
+		// Last table: also drop the blank lines that separated it from the previous one.
+		for start > 0 && strings.TrimSpace(d.lines[start-1]) == "" {
+			start--
+		}
+	}
+	return d.splice(start, end)
+}
+
+// Append adds a table with the given keys (rendered TOML values) after the last table of the same name, or at the
+// end of the document. Keys are indented like the existing tables of that name.
+func (d *Document) Append(name string, array bool, comments []string, keys [][2]string) error {
+	header := "[" + name + "]"
+	if array {
+		header = "[" + header + "]"
+	}
+	indent := ""
+	at := len(d.lines)
+	if same := d.Tables(name); len(same) > 0 {
+		last := same[len(same)-1]
+		at = last.end
+		indent = last.indent()
+	}
+
+	var lines []string
+	if at > 0 && strings.TrimSpace(d.lines[at-1]) != "" {
+		lines = append(lines, "")
+	}
+	for _, comment := range comments {
+		lines = append(lines, "# "+comment)
+	}
+	lines = append(lines, header)
+	for _, kv := range keys {
+		lines = append(lines, indent+formatKey(kv[0])+" = "+kv[1])
+	}
+	if at < len(d.lines) && strings.TrimSpace(d.lines[at]) != "" {
+		lines = append(lines, "")
+	}
+	return d.splice(at, at, lines...)
+}
+
+func (t *Table) find(key string) (keyValue, bool) {
+	for _, kv := range t.keys {
+		if kv.key == key {
+			return kv, true
+		}
+	}
+	return keyValue{}, false
+}
+
+// indent returns the indentation of the table's keys, falling back to other tables of the same name.
+func (t *Table) indent() string {
+	tables := append([]*Table{t}, t.doc.Tables(t.Name)...)
+	for _, table := range tables {
+		if len(table.keys) > 0 {
+			line := table.doc.lines[table.keys[0].start]
+			return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+	return ""
+}
+
+// splice replaces lines [start, end) with lines and re-parses the document. If the result doesn't parse, the
+// document is restored and ErrInvalidEdit is returned.
+func (d *Document) splice(start, end int, lines ...string) error {
+	before := d.lines
+	d.lines = append(d.lines[:start:start], append(lines, d.lines[end:]...)...)
+	if err := d.parse(); err != nil {
+		d.lines = before
+		_ = d.parse()
+		return fmt.Errorf("%w: %v", ErrInvalidEdit, err)
+	}
+	return nil
+}
+
+// cut returns s up to col, or all of s if col is negative.
+func cut(s string, col int) string {
+	if col < 0 {
+		return s
+	}
+	return s[:col]
+}
+
+// formatKey quotes a key that isn't a bare key.
+func formatKey(key string) string {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return String(key)
+		}
+	}
+	return key
+}
@@ -0,0 +1,299 @@
+package tomledit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const sample = `# certbot-manager configuration
+renewal_cron = "0 3 * * *" # daily
+
+[globals]
+  log_level = "info"
+
+# Certificates
+[[certificates]]
+  domains = [
+    "a.example.org", # apex
+    "www.a.example.org",
+  ]
+  "key type" = 'ecdsa'
+  pre_hook = """
+echo "# not a comment"
+"""
+
+[[certificates]]
+  domains = ["b.example.org"] # second
+  args = { "--x" = "y=z" }
+`
+
+func TestParseKeepsDocument(t *testing.T) {
+	tests := []string{
+		"",
+		sample,
+		"a = 1\r\nb = 2\r\n",
+		"\n\n# only comments\n\n",
+		"[t] # header comment\n\tkey\t=\t'tab # indented'\n",
+	}
+	for _, data := range tests {
+		doc, err := Parse([]byte(data))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", data, err)
+			continue
+		}
+		want := data
+		if want == "a = 1\r\nb = 2\r\n" {
+			want = "a = 1\nb = 2\n"
+		}
+		if got := string(doc.Bytes()); got != want {
+			t.Errorf("Bytes() of %q = %q", data, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"[]\n",
+		"[table\n",
+		"[[array]\n",
+		"key\n",
+		"key = \"unterminated\n",
+		"key = [\n  1,\n",
+		"key = \"\"\"\nnever closed\n",
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	doc, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := doc.Tables("certificates")
+	if len(certs) != 2 {
+		t.Fatalf("Tables(certificates) = %d tables, want 2", len(certs))
+	}
+	tests := []struct {
+		table *Table
+		key   string
+		want  string
+		ok    bool
+	}{
+		{doc.tables[0], "renewal_cron", `"0 3 * * *"`, true},
+		{doc.Tables("globals")[0], "log_level", `"info"`, true},
+		{certs[0], "domains", "[\n    \"a.example.org\", # apex\n    \"www.a.example.org\",\n  ]", true},
+		{certs[0], "key type", `'ecdsa'`, true},
+		{certs[0], "pre_hook", "\"\"\"\necho \"# not a comment\"\n\"\"\"", true},
+		{certs[1], "domains", `["b.example.org"]`, true},
+		{certs[1], "args", `{ "--x" = "y=z" }`, true},
+		{certs[1], "key type", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.table.Get(tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Get(%q) in [%s] = %q, %v, want %q, %v", tt.key, tt.table.Name, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, want := certs[0].Keys(), []string{"domains", "key type", "pre_hook"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		edit func(*Document) error
+		want string
+	}{
+		{
+			name: "set replaces a value and keeps its comment",
+			in:   "a = 1 # one\nb = 2\n",
+			edit: func(d *Document) error { return d.tables[0].Set("a", "3") },
+			want: "a = 3 # one\nb = 2\n",
+		},
+		{
+			name: "set replaces a multiline array",
+			in:   "[t]\n  list = [\n    1,\n    2,\n  ] # numbers\n  other = true\n",
+			edit: func(d *Document) error { return d.Tables("t")[0].Set("list", Value([]string{"x"})) },
+			want: "[t]\n  list = [\"x\"] # numbers\n  other = true\n",
+		},
+		{
+			name: "set adds a key after the last one with its indentation",
+			in:   "[t]\n  a = 1\n\n[u]\n",
+			edit: func(d *Document) error { return d.Tables("t")[0].Set("b", "2") },
+			want: "[t]\n  a = 1\n  b = 2\n\n[u]\n",
+		},
+		{
+			name: "set quotes a key that is not bare",
+			in:   "[t]\na = 1\n",
+			edit: func(d *Document) error { return d.Tables("t")[0].Set("key type", Value("rsa")) },
+			want: "[t]\na = 1\n\"key type\" = \"rsa\"\n",
+		},
+		{
+			name: "set replaces a quoted key",
+			in:   "[t]\n'key type' = 'rsa' # kept\n",
+			edit: func(d *Document) error { return d.Tables("t")[0].Set("key type", Value("ecdsa")) },
+			want: "[t]\n'key type' = \"ecdsa\" # kept\n",
+		},
+		{
+			name: "set adds a root key before the first table",
+			in:   "# header\n[t]\na = 1\n",
+			edit: func(d *Document) error { return d.tables[0].Set("root", "true") },
+			want: "root = true\n# header\n[t]\na = 1\n",
+		},
+		{
+			name: "set takes indentation from a sibling array table",
+			in:   "[[c]]\n    a = 1\n[[c]]\n",
+			edit: func(d *Document) error { return d.Tables("c")[1].Set("b", "2") },
+			want: "[[c]]\n    a = 1\n[[c]]\n    b = 2\n",
+		},
+		{
+			name: "delete removes a multiline string",
+			in:   "[t]\nhook = '''\nline [\n'''\nb = 1\n",
+			edit: func(d *Document) error { _, err := d.Tables("t")[0].Delete("hook"); return err },
+			want: "[t]\nb = 1\n",
+		},
+		{
+			name: "delete of a missing key changes nothing",
+			in:   "a = 1\n",
+			edit: func(d *Document) error { _, err := d.tables[0].Delete("b"); return err },
+			want: "a = 1\n",
+		},
+		{
+			name: "remove keeps the comment above the header",
+			in:   "# group\n[[c]]\na = 1\n\n[[c]]\na = 2\n",
+			edit: func(d *Document) error { return d.Remove(d.Tables("c")[0]) },
+			want: "# group\n[[c]]\na = 2\n",
+		},
+		{
+			name: "remove of the last table drops the blank lines before it",
+			in:   "[[c]]\na = 1\n\n\n[[c]]\na = 2 # two\n\n",
+			edit: func(d *Document) error { return d.Remove(d.Tables("c")[1]) },
+			want: "[[c]]\na = 1\n",
+		},
+		{
+			name: "append after the last table of the same name",
+			in:   "[[c]]\n  a = 1\n\n[globals]\nx = 1\n",
+			edit: func(d *Document) error {
+				return d.Append("c", true, []string{"new"}, [][2]string{{"a", "2"}, {"key type", Value("rsa")}})
+			},
+			want: "[[c]]\n  a = 1\n\n# new\n[[c]]\n  a = 2\n  \"key type\" = \"rsa\"\n\n[globals]\nx = 1\n",
+		},
+		{
+			name: "append at the end of the document",
+			in:   "a = 1\n",
+			edit: func(d *Document) error { return d.Append("t", false, nil, [][2]string{{"b", "2"}}) },
+			want: "a = 1\n\n[t]\nb = 2\n",
+		},
+		{
+			name: "append to an empty document",
+			in:   "",
+			edit: func(d *Document) error { return d.Append("c", true, nil, [][2]string{{"a", "1"}}) },
+			want: "[[c]]\na = 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.edit(doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			var v map[string]any
+			if err := toml.Unmarshal(doc.Bytes(), &v); err != nil {
+				t.Errorf("go-toml rejects the edited document: %v", err)
+			}
+		})
+	}
+}
+
+func TestInvalidEdit(t *testing.T) {
+	const in = "[t]\na = 1 # one\n"
+	tests := []struct {
+		name string
+		edit func(*Document) error
+	}{
+		{"set to an unterminated array", func(d *Document) error { return d.Tables("t")[0].Set("a", "[1,") }},
+		{"add a malformed value", func(d *Document) error { return d.Tables("t")[0].Set("b", `"unterminated`) }},
+		{"append a malformed value", func(d *Document) error { return d.Append("u", false, nil, [][2]string{{"c", "'x"}}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.edit(doc); !errors.Is(err, ErrInvalidEdit) {
+				t.Errorf("error = %v, want ErrInvalidEdit", err)
+			}
+			if got := string(doc.Bytes()); got != in {
+				t.Errorf("document after a failed edit = %q, want it unchanged", got)
+			}
+			// The document is still usable.
+			if err := doc.Tables("t")[0].Set("a", "2"); err != nil || string(doc.Bytes()) != "[t]\na = 2 # one\n" {
+				t.Errorf("edit after a failed one: %v, %q", err, doc.Bytes())
+			}
+		})
+	}
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	tests := []any{
+		"plain",
+		`quote " and backslash \`,
+		"tab\tnewline\nbell\a",
+		"# not a comment",
+		"ünïcode",
+		[]string{},
+		[]string{"a.example.org", `"quoted"`},
+		true,
+		42,
+	}
+	for _, value := range tests {
+		doc, err := Parse(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.tables[0].Set("key", Value(value)); err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.tables[0].Set("other key", Value(value)); err != nil {
+			t.Fatal(err)
+		}
+
+		var got map[string]any
+		if err := toml.Unmarshal(doc.Bytes(), &got); err != nil {
+			t.Errorf("go-toml rejects %q: %v", doc.Bytes(), err)
+			continue
+		}
+		want := value
+		switch v := value.(type) {
+		case []string:
+			items := make([]any, len(v))
+			for i, item := range v {
+				items[i] = item
+			}
+			want = items
+		case int:
+			want = int64(v)
+		}
+		for _, key := range []string{"key", "other key"} {
+			if !reflect.DeepEqual(got[key], want) {
+				t.Errorf("%s = %#v after a round trip, want %#v", key, got[key], want)
+			}
+		}
+	}
+}
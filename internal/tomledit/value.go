@@ -0,0 +1,45 @@
+package tomledit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Value renders a string, []string, bool or integer as a TOML value.
+func Value(value any) string {
+	switch v := value.(type) {
+	case string:
+		return String(v)
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = String(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// String renders a TOML basic string.
+func String(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
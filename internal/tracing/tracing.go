@@ -0,0 +1,239 @@
+// Package tracing exports OpenTelemetry spans of the manager's jobs and certificate runs. Tracing is off unless
+// the [tracing] table of the configuration selects an exporter; until then every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"certbot-manager/internal/logging"
+)
+
+// Values accepted by `tracing.exporter`.
+const (
+	ExporterNone     = "none"      // Tracing is off (also when exporter is empty)
+	ExporterOTLPGRPC = "otlp-grpc" // OTLP over gRPC, by default to localhost:4317
+	ExporterOTLPHTTP = "otlp-http" // OTLP over HTTP, by default to localhost:4318
+	ExporterStdout   = "stdout"    // Pretty-printed spans on stdout, for debugging
+)
+
+// Exporters lists the values accepted by `tracing.exporter`.
+var Exporters = []string{ExporterNone, ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterStdout}
+
+// Config is the [tracing] table of the configuration file.
+type Config struct {
+	// Span exporter: none, otlp-grpc, otlp-http or stdout
+	Exporter string `mapstructure:"exporter"`
+	// Collector address (host:port) for the OTLP exporters; defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost
+	Endpoint string `mapstructure:"endpoint"`
+	// Send OTLP without TLS
+	Insecure bool `mapstructure:"insecure"`
+	// service.name of the spans, defaults to certbot-manager
+	ServiceName string `mapstructure:"service_name"`
+}
+
+// Enabled reports whether c selects an exporter.
+func (c Config) Enabled() bool {
+	return c.Exporter != "" && c.Exporter != ExporterNone
+}
+
+// Validate checks the exporter.
+func (c Config) Validate() error {
+	if c.Exporter == "" {
+		return nil
+	}
+	for _, exporter := range Exporters {
+		if c.Exporter == exporter {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tracing.exporter '%s' (options: %v)", c.Exporter, Exporters)
+}
+
+// Span attributes set by the manager.
+const (
+	AttrTrigger       = attribute.Key("certbot_manager.trigger")     // What started the job: startup, cron, reload or manual
+	AttrRunID         = attribute.Key("certbot_manager.run_id")      // run_id of the run's log entries
+	AttrCert          = attribute.Key("certbot_manager.cert")        // Certificate or lineage name
+	AttrDomains       = attribute.Key("certbot_manager.domains")     // Domains of the certificate
+	AttrAuthenticator = attribute.Key("certbot.authenticator")       // Authenticator of the certificate's challenge
+	AttrCommand       = attribute.Key("certbot.command")             // certbot subcommand, e.g. certonly or renew
+	AttrExitCode      = attribute.Key("certbot.exit_code")           // Exit code of certbot, -1 if it couldn't be started
+	AttrErrorClass    = attribute.Key("certbot_manager.error_class") // Error class of a finished run, see certbot.ErrorClass
+	AttrPreflight     = attribute.Key("certbot_manager.preflight")   // Worst preflight status: pass, warn or fail
+	AttrHook          = attribute.Key("certbot.hook")                // Hook certbot ran: pre-hook, post-hook, deploy-hook or renew-hook
+	AttrHookCommand   = attribute.Key("certbot.hook.command")        // Command of the hook, if certbot reported it
+)
+
+// tracerName is the instrumentation scope of the manager's spans.
+const tracerName = "certbot-manager"
+
+// provider is the tracer provider installed by the last Configure, nil while tracing is off.
+var provider struct {
+	sync.Mutex
+	sdk *sdktrace.TracerProvider
+}
+
+// Configure installs the exporter selected by cfg, replacing the one of a previous Configure, whose pending spans
+// are flushed first. version is reported as service.version.
+func Configure(cfg Config, version string) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var next *sdktrace.TracerProvider
+	if cfg.Enabled() {
+		exporter, err := newExporter(cfg)
+		if err != nil {
+			return err
+		}
+		serviceName := cfg.ServiceName
+		if serviceName == "" {
+			serviceName = tracerName
+		}
+		res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version),
+		))
+		if err != nil {
+			return fmt.Errorf("failed to describe the tracing resource: %w", err)
+		}
+		next = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	}
+
+	provider.Lock()
+	previous := provider.sdk
+	provider.sdk = next
+	provider.Unlock()
+
+	if next != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			logging.Component("tracing").Warnf("Failed to export traces: %v", err)
+		}))
+		otel.SetTracerProvider(next)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	} else {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+	if previous != nil {
+		shutdown(previous)
+	}
+	return nil
+}
+
+// Shutdown flushes the pending spans and stops the exporter. Tracing is off afterwards.
+func Shutdown() {
+	provider.Lock()
+	previous := provider.sdk
+	provider.sdk = nil
+	provider.Unlock()
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	if previous != nil {
+		shutdown(previous)
+	}
+}
+
+// shutdown stops p, waiting a bounded time for its spans to be exported.
+func shutdown(p *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		logging.Component("tracing").Warnf("Failed to flush traces: %v", err)
+	}
+}
+
+// newExporter returns the span exporter selected by cfg. The OTLP exporters also read the standard OTEL_EXPORTER_OTLP_*
+// environment variables, e.g. for headers.
+func newExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case ExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing.exporter '%s' (options: %v)", cfg.Exporter, Exporters)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+	return exporter, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span with the outcome of its work: err, if any, is recorded with its error class.
+func End(span trace.Span, err error, errorClass string) {
+	if errorClass != "" {
+		span.SetAttributes(AttrErrorClass.String(errorClass))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogFields returns the trace and span IDs of the span in ctx as log fields, so log entries can be joined with
+// their trace. It returns nil while tracing is off.
+func LogFields(ctx context.Context) logrus.Fields {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return logrus.Fields{
+		logging.FieldTraceID: sc.TraceID().String(),
+		logging.FieldSpanID:  sc.SpanID().String(),
+	}
+}
+
+// WithSpan adds the trace and span IDs of the span in ctx to log.
+func WithSpan(ctx context.Context, log *logrus.Entry) *logrus.Entry {
+	if fields := LogFields(ctx); fields != nil {
+		return log.WithFields(fields)
+	}
+	return log
+}
+
+// ErrFailed is recorded on job spans whose certificate runs failed; the runs' own spans carry the errors.
+var ErrFailed = errors.New("one or more certificate runs failed")
@@ -0,0 +1,42 @@
+package watchdog
+
+import (
+	"time"
+
+	"certbot-manager/internal/cron"
+	"certbot-manager/internal/preflight"
+)
+
+func init() { preflight.RegisterGlobal(checkExpiryWatchdog) }
+
+// checkExpiryWatchdog checks that the watchdog's schedule parses and that its extra certificate files are readable.
+func checkExpiryWatchdog(env preflight.Env) []preflight.Result {
+	const check = "expiry_check_cron"
+	globals := env.Config.Globals
+	if globals.ExpiryCheckCron == "" {
+		return []preflight.Result{preflight.Warn(check, "set expiry_check_cron, e.g. \"0 30 * * * *\", to be warned of stale certificates",
+			"not set; the expiry watchdog is disabled")}
+	}
+	runs, err := cron.NextRuns(globals.ExpiryCheckCron, time.Now(), 1)
+	if err != nil {
+		return []preflight.Result{preflight.Fail(check, "use a six-field expression with seconds, e.g. \"0 30 * * * *\"", "%v", err)}
+	}
+	if len(runs) == 0 {
+		return []preflight.Result{preflight.Fail(check, "use an expression that fires regularly", "'%s' never fires", globals.ExpiryCheckCron)}
+	}
+	results := []preflight.Result{preflight.Pass(check, "'%s', warning at %d days, critical at %d days",
+		globals.ExpiryCheckCron, globals.ExpiryWarningDays, globals.ExpiryCriticalDays)}
+
+	for _, r := range checkPaths(globals.ExpiryCheckPaths, ThresholdsOf(globals), time.Now()) {
+		switch r.Severity {
+		case SeverityUnreadable:
+			results = append(results, preflight.Fail("expiry_check_paths", "list PEM certificate files readable by certbot-manager", "%s", r.Error))
+		case SeverityOK:
+			results = append(results, preflight.Pass("expiry_check_paths", "'%s' expires in %d days", r.Path, *r.DaysRemaining))
+		default:
+			results = append(results, preflight.Warn("expiry_check_paths", "renew or replace the certificate",
+				"'%s' expires in %d days (%s)", r.Path, *r.DaysRemaining, r.Severity))
+		}
+	}
+	return results
+}
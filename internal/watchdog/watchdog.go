@@ -0,0 +1,218 @@
+// Package watchdog checks the expiry of certificate files on disk, independently of what certbot reports. A renewal
+// that keeps "succeeding" while a certificate stays stale, e.g. because its renewal config points at a dead
+// authenticator, is caught once the certificate's not_after comes close.
+package watchdog
+
+import (
+	"crypto/x509"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"certbot-manager/internal/config"
+	"certbot-manager/internal/letsencrypt"
+	"certbot-manager/internal/logging"
+	"certbot-manager/internal/metrics"
+)
+
+// Severities of a certificate file, from best to worst.
+const (
+	SeverityOK         = "ok"
+	SeverityWarning    = "warning"    // Fewer than expiry_warning_days left
+	SeverityCritical   = "critical"   // Fewer than expiry_critical_days left
+	SeverityExpired    = "expired"    // not_after has passed
+	SeverityUnreadable = "unreadable" // The file is missing or isn't a PEM certificate
+)
+
+// severities orders the severities; the index is the value of the watchdog_severity metric.
+var severities = []string{SeverityOK, SeverityWarning, SeverityCritical, SeverityExpired, SeverityUnreadable}
+
+// remindEvery is how often an event is repeated while a certificate's severity doesn't change.
+const remindEvery = 24 * time.Hour
+
+// Thresholds are the days before expiry at which a certificate becomes a warning and then critical.
+type Thresholds struct {
+	WarningDays  int
+	CriticalDays int
+}
+
+// ThresholdsOf returns the thresholds configured in globals.
+func ThresholdsOf(globals config.Globals) Thresholds {
+	return Thresholds{WarningDays: globals.ExpiryWarningDays, CriticalDays: globals.ExpiryCriticalDays}
+}
+
+// Classify returns the severity of a certificate expiring at notAfter, and the whole days it has left.
+func Classify(notAfter, now time.Time, t Thresholds) (severity string, days int) {
+	days = int(notAfter.Sub(now).Hours() / 24)
+	switch {
+	case !now.Before(notAfter):
+		return SeverityExpired, days
+	case days < t.CriticalDays:
+		return SeverityCritical, days
+	case days < t.WarningDays:
+		return SeverityWarning, days
+	}
+	return SeverityOK, days
+}
+
+// Result is the outcome of the check of one certificate file.
+type Result struct {
+	Name          string     `json:"name"` // Lineage name, or the path of an extra certificate
+	Path          string     `json:"path"`
+	Lineage       bool       `json:"lineage"`
+	NotAfter      *time.Time `json:"not_after,omitempty"`
+	DaysRemaining *int       `json:"days_remaining,omitempty"`
+	Severity      string     `json:"severity"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// Check reads the live certificate of every lineage in configDir, except those whose renewal was stopped on purpose,
+// and every file in paths. A lineage whose renewal config can't be read is SeverityUnreadable, as is configDir if
+// its lineages can't be listed; the other certificates are checked regardless.
+func Check(configDir string, paths []string, t Thresholds, now time.Time) []Result {
+	var results []Result
+	lineages, err := letsencrypt.Lineages(configDir)
+	if err != nil {
+		renewalDir := filepath.Join(configDir, "renewal")
+		results = append(results, Result{Name: renewalDir, Path: renewalDir, Lineage: true, Severity: SeverityUnreadable, Error: err.Error()})
+	}
+	for _, lineage := range lineages {
+		switch {
+		case lineage.Disabled:
+			continue
+		case lineage.Err != nil:
+			results = append(results, result(lineage.Name, lineage.CertPath, true, nil, lineage.Err, t, now))
+		default:
+			results = append(results, result(lineage.Name, lineage.CertPath, true, lineage.Cert, lineage.CertErr, t, now))
+		}
+	}
+	return append(results, checkPaths(paths, t, now)...)
+}
+
+// checkPaths reads the certificate files in paths.
+func checkPaths(paths []string, t Thresholds, now time.Time) []Result {
+	var results []Result
+	for _, path := range paths {
+		cert, err := letsencrypt.ReadCertificate(path)
+		results = append(results, result(path, path, false, cert, err, t, now))
+	}
+	return results
+}
+
+// Watchdog runs the checks and raises an event whenever a certificate's severity changes, repeated daily while it
+// isn't ok.
+type Watchdog struct {
+	mu       sync.Mutex
+	reported map[string]report // By path
+}
+
+// report is the last event raised for a certificate file.
+type report struct {
+	severity string
+	at       time.Time
+}
+
+// New returns a watchdog that hasn't raised any event yet.
+func New() *Watchdog {
+	return &Watchdog{reported: map[string]report{}}
+}
+
+// Run checks the lineages and expiry_check_paths of globals, records the results in the metrics and raises the due
+// events as log entries: warnings for SeverityWarning, errors for worse.
+func (w *Watchdog) Run(globals config.Globals) []Result {
+	now := time.Now()
+	results := Check(globals.ConfigDir, globals.ExpiryCheckPaths, ThresholdsOf(globals), now)
+	w.observe(results, now)
+	return results
+}
+
+// observe records results in the metrics and raises the due events. It returns the results that raised one.
+func (w *Watchdog) observe(results []Result, now time.Time) []Result {
+	log := logging.Component("watchdog")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var raised []Result
+	checks := make([]metrics.ExpiryCheck, 0, len(results))
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		seen[r.Path] = true
+		check := metrics.ExpiryCheck{Name: r.Name, Path: r.Path, Severity: severityValue(r.Severity)}
+		if r.NotAfter != nil {
+			check.NotAfter = *r.NotAfter
+		}
+		checks = append(checks, check)
+
+		previous, known := w.reported[r.Path]
+		changed := (known && previous.severity != r.Severity) || (!known && r.Severity != SeverityOK)
+		remind := known && r.Severity != SeverityOK && now.Sub(previous.at) >= remindEvery
+		if changed || remind {
+			raise(log, r)
+			raised = append(raised, r)
+		}
+		if changed || remind || !known {
+			w.reported[r.Path] = report{severity: r.Severity, at: now}
+		}
+	}
+	for path := range w.reported {
+		if !seen[path] {
+			delete(w.reported, path) // Removed lineage or path
+		}
+	}
+	metrics.ObserveExpiryCheck(now, checks)
+	log.Debugf("Checked the expiry of %d certificate(s).", len(results))
+	return raised
+}
+
+// raise logs the event of a certificate whose severity changed or persists.
+func raise(log *logrus.Entry, r Result) {
+	entry := log.WithFields(logrus.Fields{
+		logging.FieldCert:     r.Name,
+		logging.FieldPath:     r.Path,
+		logging.FieldSeverity: r.Severity,
+	})
+	if r.DaysRemaining != nil {
+		entry = entry.WithField(logging.FieldDaysRemaining, *r.DaysRemaining)
+	}
+	metrics.ObserveExpiryEvent(r.Severity)
+
+	switch r.Severity {
+	case SeverityOK:
+		entry.Infof("Certificate '%s' is no longer close to expiry: it expires on %s.", r.Name, r.NotAfter.Format(time.RFC3339))
+	case SeverityWarning:
+		entry.Warnf("Certificate '%s' expires in %d days, on %s.", r.Name, *r.DaysRemaining, r.NotAfter.Format(time.RFC3339))
+	case SeverityCritical:
+		entry.Errorf("Certificate '%s' expires in %d days, on %s, and hasn't been renewed.", r.Name, *r.DaysRemaining, r.NotAfter.Format(time.RFC3339))
+	case SeverityExpired:
+		entry.Errorf("Certificate '%s' expired on %s.", r.Name, r.NotAfter.Format(time.RFC3339))
+	default:
+		entry.Errorf("Certificate '%s' can't be checked: %s", r.Name, r.Error)
+	}
+}
+
+// result describes a certificate file, or why it couldn't be read.
+func result(name, path string, lineage bool, cert *x509.Certificate, err error, t Thresholds, now time.Time) Result {
+	r := Result{Name: name, Path: path, Lineage: lineage}
+	if cert == nil {
+		r.Severity = SeverityUnreadable
+		if err != nil {
+			r.Error = err.Error()
+		}
+		return r
+	}
+	notAfter := cert.NotAfter
+	severity, days := Classify(notAfter, now, t)
+	r.NotAfter, r.DaysRemaining, r.Severity = &notAfter, &days, severity
+	return r
+}
+
+// severityValue returns the metric value of a severity.
+func severityValue(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severities) - 1
+}
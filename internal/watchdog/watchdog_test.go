@@ -0,0 +1,185 @@
+package watchdog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestClassify(t *testing.T) {
+	thresholds := Thresholds{WarningDays: 14, CriticalDays: 3}
+	tests := []struct {
+		notAfter time.Time
+		severity string
+		days     int
+	}{
+		{now.AddDate(0, 0, 60), SeverityOK, 60},
+		{now.AddDate(0, 0, 14), SeverityOK, 14},
+		{now.AddDate(0, 0, 14).Add(-time.Minute), SeverityWarning, 13},
+		{now.AddDate(0, 0, 3), SeverityWarning, 3},
+		{now.AddDate(0, 0, 3).Add(-time.Minute), SeverityCritical, 2},
+		{now.Add(time.Minute), SeverityCritical, 0},
+		{now, SeverityExpired, 0},
+		{now.AddDate(0, 0, -2), SeverityExpired, -2},
+	}
+	for _, tt := range tests {
+		severity, days := Classify(tt.notAfter, now, thresholds)
+		if severity != tt.severity || days != tt.days {
+			t.Errorf("Classify(%s) = %s, %d, want %s, %d", tt.notAfter, severity, days, tt.severity, tt.days)
+		}
+	}
+}
+
+// writeCert writes a self-signed certificate expiring at notAfter to path.
+func writeCert(t *testing.T, path string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: filepath.Base(path)},
+		NotBefore:    notAfter.AddDate(0, 0, -90),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "letsencrypt")
+	writeFile(t, filepath.Join(configDir, "renewal", "ok.conf"), "version = 2.0\n")
+	writeCert(t, filepath.Join(configDir, "live", "ok", "cert.pem"), now.AddDate(0, 0, 60))
+	writeFile(t, filepath.Join(configDir, "renewal", "stopped.conf.disabled"), "version = 2.0\n")
+	writeFile(t, filepath.Join(configDir, "renewal", "broken.conf"), "not a renewal config\n")
+	writeCert(t, filepath.Join(configDir, "live", "broken", "cert.pem"), now.AddDate(0, 0, 60))
+	writeFile(t, filepath.Join(configDir, "renewal", "missing.conf"), "version = 2.0\n")
+	extra := filepath.Join(dir, "extra.pem")
+	writeCert(t, extra, now.AddDate(0, 0, 5))
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name      string
+		configDir string
+		want      map[string]string // Result name to severity
+	}{
+		{
+			name:      "lineages and extra paths",
+			configDir: configDir,
+			want: map[string]string{
+				"ok":      SeverityOK,
+				"broken":  SeverityUnreadable,
+				"missing": SeverityUnreadable,
+				extra:     SeverityWarning,
+				missing:   SeverityUnreadable,
+			},
+		},
+		{
+			name:      "extra paths without certbot",
+			configDir: filepath.Join(dir, "none"),
+			want:      map[string]string{extra: SeverityWarning, missing: SeverityUnreadable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Check(tt.configDir, []string{extra, missing}, Thresholds{WarningDays: 14, CriticalDays: 3}, now)
+			got := make(map[string]string, len(results))
+			for _, r := range results {
+				got[r.Name] = r.Severity
+				if r.Severity == SeverityUnreadable && r.Error == "" {
+					t.Errorf("%s is unreadable without an error", r.Name)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Check = %v, want %v", got, tt.want)
+			}
+			for name, severity := range tt.want {
+				if got[name] != severity {
+					t.Errorf("%s: severity = %q, want %q", name, got[name], severity)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckUnlistableConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "letsencrypt")
+	writeFile(t, filepath.Join(configDir, "renewal"), "a file, not a directory\n")
+	extra := filepath.Join(dir, "extra.pem")
+	writeCert(t, extra, now.AddDate(0, 0, 60))
+
+	results := Check(configDir, []string{extra}, Thresholds{WarningDays: 14, CriticalDays: 3}, now)
+	if len(results) != 2 || results[0].Severity != SeverityUnreadable || results[1].Name != extra || results[1].Severity != SeverityOK {
+		t.Errorf("Check = %+v, want the renewal directory unreadable and the extra path ok", results)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	result := func(severity string) []Result {
+		notAfter := now.AddDate(0, 0, 30)
+		days := 30
+		return []Result{{Name: "a.org", Path: "/live/a.org/cert.pem", Lineage: true, NotAfter: &notAfter, DaysRemaining: &days, Severity: severity}}
+	}
+	steps := []struct {
+		after    time.Duration // Since the start
+		severity string
+		raised   bool
+	}{
+		{0, SeverityOK, false},                      // First check, nothing to report
+		{time.Hour, SeverityOK, false},              // Still ok
+		{2 * time.Hour, SeverityWarning, true},      // Changed
+		{3 * time.Hour, SeverityWarning, false},     // Reported less than a day ago
+		{26 * time.Hour, SeverityWarning, true},     // Daily reminder
+		{27 * time.Hour, SeverityCritical, true},    // Changed
+		{28 * time.Hour, SeverityOK, true},          // Recovered
+		{60 * time.Hour, SeverityOK, false},         // No reminder while ok
+		{61 * time.Hour, SeverityUnreadable, true},  // Changed
+		{62 * time.Hour, SeverityUnreadable, false}, // Reported less than a day ago
+		{86 * time.Hour, SeverityUnreadable, true},  // Daily reminder
+		{87 * time.Hour, "", false},                 // Removed: forgotten
+		{88 * time.Hour, SeverityExpired, true},     // New and not ok
+	}
+	w := New()
+	for _, step := range steps {
+		var results []Result
+		if step.severity != "" {
+			results = result(step.severity)
+		}
+		raised := w.observe(results, now.Add(step.after))
+		if (len(raised) > 0) != step.raised {
+			t.Errorf("after %s with %q: raised %v, want %v", step.after, step.severity, len(raised) > 0, step.raised)
+		}
+	}
+}